@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zberg/go-at2plus/pkg/at2plus"
+)
+
+// Values accepted by the control flags. They are the control subsets of
+// the library enums: e.g. auto-heat is a mode the AC reports, not one it
+// can be set to.
+var (
+	groupPowerChoices = []at2plus.GroupPowerCommand{
+		at2plus.GroupPowerOn, at2plus.GroupPowerOff, at2plus.GroupPowerTurbo, at2plus.GroupPowerNext,
+	}
+	acPowerChoices = []at2plus.ACPowerCommand{
+		at2plus.ACPowerOn, at2plus.ACPowerOff, at2plus.ACPowerToggle, at2plus.ACPowerAway, at2plus.ACPowerSleep,
+	}
+	acModeChoices = []at2plus.ACMode{
+		at2plus.ACModeAuto, at2plus.ACModeHeat, at2plus.ACModeDry, at2plus.ACModeFan, at2plus.ACModeCool,
+	}
+	fanSpeedChoices = []at2plus.FanSpeed{
+		at2plus.FanSpeedAuto, at2plus.FanSpeedQuiet, at2plus.FanSpeedLow, at2plus.FanSpeedMedium,
+		at2plus.FanSpeedHigh, at2plus.FanSpeedPowerful, at2plus.FanSpeedTurbo,
+	}
+	unitChoices   = []string{"C", "F"}
+	outputChoices = []string{outputTable, outputJSON, outputYAML, outputTemplate + "="}
+)
+
+// choiceNames returns the names of choices, as the Parse functions take
+// them.
+func choiceNames[T fmt.Stringer](choices []T) []string {
+	names := make([]string, len(choices))
+	for i, c := range choices {
+		names[i] = c.String()
+	}
+	return names
+}
+
+// parseChoice parses the value of a flag and accepts it only if it is one
+// of choices.
+func parseChoice[T interface {
+	comparable
+	fmt.Stringer
+}](flag, s string, parse func(string) (T, error), choices []T) (T, error) {
+	v, err := parse(s)
+	if err != nil || !slices.Contains(choices, v) {
+		var zero T
+		return zero, usagef("invalid --%s %q (valid: %s)", flag, s, strings.Join(choiceNames(choices), ", "))
+	}
+	return v, nil
+}
+
+// completeFlag registers the shell completions of a flag.
+func completeFlag(cmd *cobra.Command, flag string, values []string) {
+	cmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// completeDevices completes --device with the aliases in the registry.
+func completeDevices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var aliases []string
+	for _, d := range reg.Devices {
+		aliases = append(aliases, d.Alias)
+	}
+	return aliases, cobra.ShellCompDirectiveNoFileComp
+}
@@ -2,9 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"net/netip"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,256 +18,918 @@ import (
 )
 
 var (
-	targetIP string
+	targetIP   string
+	deviceFlag string
+	unitFlag   string
+	outputFlag string
+
+	// unit is the parsed --unit flag.
+	unit at2plus.TemperatureUnit
 )
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&targetIP, "ip", "", "IP address of the AirTouch 2+ unit")
+	rootCmd.PersistentFlags().StringVar(&targetIP, "ip", "", "IP address, MAC address or device ID of the AirTouch 2+ unit")
+	rootCmd.PersistentFlags().StringVar(&deviceFlag, "device", "", "Alias of a unit saved with discover --save")
+	rootCmd.PersistentFlags().StringVar(&unitFlag, "unit", "C", "Temperature unit for input and table output (C, F)")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", outputTable, "Output format: table, json, yaml or template=<Go template>")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		var err error
+		if unit, err = at2plus.ParseTemperatureUnit(unitFlag); err != nil {
+			return usagef("invalid unit: %v", err)
+		}
+		out, err = newPrinter(outputFlag, os.Stdout)
+		return err
+	}
 
 	rootCmd.AddCommand(discoverCmd)
+	rootCmd.AddCommand(devicesCmd)
+	devicesCmd.AddCommand(devicesRemoveCmd, devicesRenameCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(controlGroupCmd)
+	controlGroupCmd.AddCommand(groupSetCmd)
 	rootCmd.AddCommand(controlACCmd)
+	rootCmd.AddCommand(errorsCmd)
+	rootCmd.AddCommand(abilitiesCmd)
+	rootCmd.AddCommand(namesCmd)
+
+	completeFlag(rootCmd, "unit", unitChoices)
+	completeFlag(rootCmd, "output", outputChoices)
+	rootCmd.RegisterFlagCompletionFunc("device", completeDevices)
 }
 
 var discoverCmd = &cobra.Command{
 	Use:   "discover",
 	Short: "Discover AirTouch 2+ devices on the network",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
-		fmt.Println("Discovering devices...")
-		results, err := at2plus.Discover(ctx)
+		var opts []at2plus.DiscoverOption
+		if noVerify, _ := cmd.Flags().GetBool("no-verify"); !noVerify {
+			opts = append(opts, at2plus.WithHandshake())
+		}
+		if targets, _ := cmd.Flags().GetStringSlice("target"); len(targets) > 0 {
+			opts = append(opts, at2plus.WithTargets(targets...))
+		}
+		if ifaces, _ := cmd.Flags().GetStringSlice("iface"); len(ifaces) > 0 {
+			opts = append(opts, at2plus.WithInterfaces(ifaces...))
+		}
+		if ports, _ := cmd.Flags().GetIntSlice("port"); len(ports) > 0 {
+			opts = append(opts, at2plus.WithPorts(ports...))
+		}
+		if cmd.Flags().Changed("dial-timeout") {
+			d, _ := cmd.Flags().GetDuration("dial-timeout")
+			opts = append(opts, at2plus.WithDialTimeout(d))
+		}
+		if cmd.Flags().Changed("workers") {
+			n, _ := cmd.Flags().GetInt("workers")
+			opts = append(opts, at2plus.WithWorkers(n))
+		}
+
+		if noBroadcast, _ := cmd.Flags().GetBool("no-broadcast"); !noBroadcast {
+			opts = append(opts, at2plus.WithBroadcast())
+		}
+		if cmd.Flags().Changed("broadcast-wait") {
+			d, _ := cmd.Flags().GetDuration("broadcast-wait")
+			opts = append(opts, at2plus.WithBroadcastWait(d))
+		}
+		if addrs, _ := cmd.Flags().GetStringSlice("broadcast-addr"); len(addrs) > 0 {
+			opts = append(opts, at2plus.WithBroadcastAddrs(addrs...))
+		}
+
+		save, _ := cmd.Flags().GetBool("save")
+		alias, _ := cmd.Flags().GetString("alias")
+		if alias != "" {
+			if !save {
+				return usagef("--alias requires --save")
+			}
+			if err := checkAlias(alias); err != nil {
+				return usageError{err}
+			}
+		}
+
+		notef("Discovering devices...\n")
+		// A device found by both the broadcast and the scan is reported
+		// again with the new details; print each detail once
+		type printed struct {
+			id                string
+			console, verified bool
+		}
+		seen := make(map[string]*printed)
+		results := make(map[string]at2plus.DiscoveryResult)
+		err := at2plus.DiscoverEach(ctx, func(res at2plus.DiscoveryResult) {
+			results[res.IP] = res
+			if out.structured() {
+				return
+			}
+			p, ok := seen[res.IP]
+			if !ok {
+				p = &printed{}
+				seen[res.IP] = p
+				addr := res.IP
+				if res.Port != 9200 {
+					addr = fmt.Sprintf("%s:%d", res.IP, res.Port)
+				}
+				fmt.Printf("Found device at: %s\n", addr)
+			}
+			if res.DeviceID != "" && res.DeviceID != p.id {
+				p.id = res.DeviceID
+				fmt.Printf("  Device ID: %s\n", res.DeviceID)
+				if res.MAC != "" {
+					fmt.Printf("  MAC: %s", res.MAC)
+					if res.Vendor != "" {
+						fmt.Printf(" (%s)", res.Vendor)
+					}
+					fmt.Println()
+				}
+			}
+			if res.ConsoleID != "" && !p.console {
+				p.console = true
+				fmt.Printf("  Console: %s (%s", res.ConsoleID, res.DeviceType)
+				if res.AirTouchID != "" {
+					fmt.Printf(", AirTouch ID %s", res.AirTouchID)
+				}
+				if res.Name != "" {
+					fmt.Printf(", %q", res.Name)
+				}
+				fmt.Println(")")
+			}
+			if res.Verified && !p.verified {
+				p.verified = true
+				fmt.Printf("  %d groups, %d ACs, latency %s\n",
+					res.GroupCount, res.ACCount, res.Latency.Round(time.Millisecond))
+				for i, name := range res.ACNames {
+					fmt.Printf("  AC %d: %s\n", i, name)
+				}
+			}
+		}, opts...)
 		if err != nil {
-			fmt.Printf("Error discovering: %v\n", err)
-			return
+			return fmt.Errorf("discover: %w", err)
 		}
 
-		if len(results) == 0 {
+		if out.structured() {
+			list := slices.SortedFunc(maps.Values(results), func(a, b at2plus.DiscoveryResult) int {
+				return netip.MustParseAddr(a.IP).Compare(netip.MustParseAddr(b.IP))
+			})
+			if list == nil {
+				list = []at2plus.DiscoveryResult{}
+			}
+			if err := out.print(list, nil); err != nil {
+				return err
+			}
+		} else if len(results) == 0 {
 			fmt.Println("No devices found.")
-			return
 		}
 
-		for _, res := range results {
-			fmt.Printf("Found device at: %s\n", res.IP)
+		if save && len(results) > 0 {
+			return saveDevices(results, alias)
 		}
+		return nil
 	},
 }
 
-var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "Show status of groups and ACs",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+// saveDevices records discovered units in the device registry.
+func saveDevices(results map[string]at2plus.DiscoveryResult, alias string) error {
+	if alias != "" && len(results) != 1 {
+		return fmt.Errorf("--alias needs exactly one device, found %d", len(results))
+	}
+	reg, err := loadRegistry()
+	if err != nil {
+		return fmt.Errorf("load device registry: %w", err)
+	}
 
-		client := getClient(ctx)
-		defer client.Close()
+	ips := slices.Sorted(maps.Keys(results))
+	for _, ip := range ips {
+		dev, err := reg.upsert(results[ip], alias)
+		if err != nil {
+			return fmt.Errorf("save %s: %w", ip, err)
+		}
+		notef("Saved %s as %q\n", ip, dev.Alias)
+	}
+	if err := reg.save(); err != nil {
+		return fmt.Errorf("save device registry: %w", err)
+	}
+	return nil
+}
 
-		fmt.Println("Fetching Group Status...")
-		groups, err := client.GetGroupStatus(ctx)
+var devicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "List units saved with discover --save",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reg, err := loadRegistry()
 		if err != nil {
-			fmt.Printf("Error getting group status: %v\n", err)
-		} else {
-			for _, g := range groups {
-				powerStr := "OFF"
-				if g.Power == 1 {
-					powerStr = "ON"
-				}
-				if g.Power == 3 {
-					powerStr = "TURBO"
+			return fmt.Errorf("load device registry: %w", err)
+		}
+		if reg.Devices == nil {
+			reg.Devices = []*registryDevice{}
+		}
+		return out.print(reg.Devices, func(w *tabwriter.Writer) {
+			if len(reg.Devices) == 0 {
+				fmt.Fprintln(w, "No saved devices. Run discover --save first.")
+				return
+			}
+			fmt.Fprintln(w, "ALIAS\tADDRESS\tDEVICE ID\tLAST SEEN")
+			for _, d := range reg.Devices {
+				addr := d.IP
+				if d.Port != 0 && d.Port != 9200 {
+					addr = fmt.Sprintf("%s:%d", d.IP, d.Port)
 				}
-
-				fmt.Printf("Group %d: Power=%s, Open=%d%%\n", g.GroupNumber, powerStr, g.Percent)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Alias, addr, d.DeviceID, d.LastSeen.Local().Format(time.DateTime))
 			}
+		})
+	},
+}
+
+var devicesRemoveCmd = &cobra.Command{
+	Use:   "remove [alias]",
+	Short: "Remove a saved unit",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reg, err := loadRegistry()
+		if err != nil {
+			return fmt.Errorf("load device registry: %w", err)
+		}
+		if !reg.remove(args[0]) {
+			return fmt.Errorf("unknown device %q", args[0])
 		}
+		if err := reg.save(); err != nil {
+			return fmt.Errorf("save device registry: %w", err)
+		}
+		return nil
+	},
+}
 
-		fmt.Println("\nFetching AC Status...")
-		acs, err := client.GetACStatus(ctx)
+var devicesRenameCmd = &cobra.Command{
+	Use:   "rename [alias] [new-alias]",
+	Short: "Change the alias of a saved unit",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkAlias(args[1]); err != nil {
+			return usageError{err}
+		}
+		reg, err := loadRegistry()
 		if err != nil {
-			fmt.Printf("Error getting AC status: %v\n", err)
-		} else {
-			for _, ac := range acs {
-				powerStr := "OFF"
-				if ac.Power == 1 {
-					powerStr = "ON"
-				}
+			return fmt.Errorf("load device registry: %w", err)
+		}
+		dev := reg.find(args[0])
+		if dev == nil {
+			return fmt.Errorf("unknown device %q", args[0])
+		}
+		if other := reg.find(args[1]); other != nil && other != dev {
+			return fmt.Errorf("alias %q is already used", args[1])
+		}
+		dev.Alias = args[1]
+		if err := reg.save(); err != nil {
+			return fmt.Errorf("save device registry: %w", err)
+		}
+		return nil
+	},
+}
 
-				modeStr := "AUTO"
-				switch ac.Mode {
-				case 1:
-					modeStr = "HEAT"
-				case 2:
-					modeStr = "DRY"
-				case 3:
-					modeStr = "FAN"
-				case 4:
-					modeStr = "COOL"
-				}
+// statusResult is the output of the status command.
+type statusResult struct {
+	Groups []at2plus.GroupStatus `json:"groups"`
+	ACs    []at2plus.ACStatus    `json:"acs"`
+}
 
-				fmt.Printf("AC %d: Power=%s, Mode=%s, Temp=%d, Setpoint=%d\n", ac.ACNumber, powerStr, modeStr, ac.Temperature, ac.Setpoint)
-			}
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show status of groups and ACs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := getClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		var res statusResult
+		if res.Groups, err = client.GetGroupStatus(ctx); err != nil {
+			return err
 		}
+		if res.ACs, err = client.GetACStatus(ctx); err != nil {
+			return err
+		}
+
+		return out.print(res, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "GROUP\tPOWER\tOPEN")
+			for _, g := range res.Groups {
+				fmt.Fprintf(w, "%d\t%s\t%d%%\n", g.GroupNumber, strings.ToUpper(g.Power.String()), g.Percent)
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "AC\tPOWER\tMODE\tFAN\tTEMP\tSETPOINT")
+			for _, ac := range res.ACs {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", ac.ACNumber,
+					strings.ToUpper(ac.Power.String()), strings.ToUpper(ac.Mode.String()),
+					strings.ToUpper(ac.FanSpeed.String()), ac.Temperature.Format(unit), ac.Setpoint.Format(unit))
+			}
+		})
 	},
 }
 
 var controlGroupCmd = &cobra.Command{
-	Use:   "control-group [group-number]",
-	Short: "Control a group",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		groupNum, err := strconv.Atoi(args[0])
-		if err != nil {
-			fmt.Printf("Invalid group number '%s': must be a number\n", args[0])
-			os.Exit(1)
-		}
-		if groupNum < 0 || groupNum > 15 {
-			fmt.Printf("Invalid group number %d: must be 0-15\n", groupNum)
-			os.Exit(1)
+	Use:     "group <group>",
+	Aliases: []string{"control-group"},
+	Short:   "Control a group, given by number or name",
+	Example: `  at2plus group kitchen --percent 50
+  at2plus group "bed 2" --power off
+  at2plus group 3 --inc`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkUnitNumber("group", args[0], 15); err != nil {
+			return err
 		}
 
 		powerStr, _ := cmd.Flags().GetString("power")
 		percent, _ := cmd.Flags().GetInt("percent")
+		inc, _ := cmd.Flags().GetBool("inc")
+		dec, _ := cmd.Flags().GetBool("dec")
 
-		var power *int
+		var power *at2plus.GroupPowerCommand
 		if powerStr != "" {
-			p := 0 // Next
-			if powerStr == "off" {
-				p = 1
-			}
-			if powerStr == "on" {
-				p = 2
-			}
-			if powerStr == "turbo" {
-				p = 3
+			p, err := parseChoice("power", powerStr, at2plus.ParseGroupPowerCommand, groupPowerChoices)
+			if err != nil {
+				return err
 			}
 			power = &p
 		}
 
-		var pct *int
-		if cmd.Flags().Changed("percent") {
+		var (
+			value *at2plus.GroupValue
+			pct   *int
+		)
+		switch {
+		case cmd.Flags().Changed("percent"):
+			if percent < 0 || percent > 100 {
+				return usagef("invalid --percent %d: must be 0-100", percent)
+			}
 			pct = &percent
+		case inc:
+			value = at2plus.Ptr(at2plus.GroupValueIncrease)
+		case dec:
+			value = at2plus.Ptr(at2plus.GroupValueDecrease)
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		client := getClient(ctx)
+		client, err := getClient(ctx, confirmOptions(cmd)...)
+		if err != nil {
+			return err
+		}
 		defer client.Close()
 
+		groupNum, err := client.ResolveGroup(ctx, args[0])
+		if err != nil {
+			return nameError(err)
+		}
+
 		err = client.SetGroupControl(ctx, []at2plus.GroupControl{
 			{
-				GroupNumber: uint8(groupNum),
+				GroupNumber: groupNum,
 				Power:       power,
+				Value:       value,
 				Percent:     pct,
 			},
 		})
-
 		if err != nil {
-			fmt.Printf("Error controlling group: %v\n", err)
-		} else {
+			return err
+		}
+		if !out.structured() {
 			fmt.Println("Command sent successfully.")
+			return nil
+		}
+
+		// Report the state the group is in now
+		groups, err := client.GetGroupStatus(ctx)
+		if err != nil {
+			return err
 		}
+		for _, g := range groups {
+			if g.GroupNumber == groupNum {
+				return out.print(g, nil)
+			}
+		}
+		return fmt.Errorf("group %d not found", groupNum)
 	},
 }
 
-var controlACCmd = &cobra.Command{
-	Use:   "control-ac [ac-number]",
-	Short: "Control an AC",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		acNum, err := strconv.Atoi(args[0])
+var groupSetCmd = &cobra.Command{
+	Use:   "set <group>=<setting>[:<setting>...]...",
+	Short: "Change several groups at once, in a single packet",
+	Long: `Change several groups at once. Each argument names a group, by number or
+name, and the settings to apply to it, separated by colons: a power state
+(` + strings.Join(choiceNames(groupPowerChoices), ", ") + `), an open percentage (0-100) or inc/dec to open or
+close the damper by 5%. All changes are sent together.`,
+	Example: `  at2plus group set 1=on:60 3=off 5=turbo
+  at2plus group set kitchen=40 "bed 2=inc"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		refs := make([]string, len(args))
+		ctrls := make([]at2plus.GroupControl, len(args))
+		for i, arg := range args {
+			var err error
+			if refs[i], ctrls[i], err = parseGroupSetting(arg); err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := getClient(ctx, confirmOptions(cmd)...)
 		if err != nil {
-			fmt.Printf("Invalid AC number '%s': must be a number\n", args[0])
-			os.Exit(1)
+			return err
+		}
+		defer client.Close()
+
+		batch := client.Batch()
+		changed := make(map[uint8]bool)
+		for i, ref := range refs {
+			num, err := client.ResolveGroup(ctx, ref)
+			if err != nil {
+				return nameError(err)
+			}
+			ctrls[i].GroupNumber = num
+			batch.Group(ctrls[i])
+			changed[num] = true
+		}
+		if err := batch.Send(ctx); err != nil {
+			return err
+		}
+		if !out.structured() {
+			fmt.Println("Command sent successfully.")
+			return nil
+		}
+
+		// Report the state the groups are in now
+		groups, err := client.GetGroupStatus(ctx)
+		if err != nil {
+			return err
+		}
+		result := make([]at2plus.GroupStatus, 0, len(changed))
+		for _, g := range groups {
+			if changed[g.GroupNumber] {
+				result = append(result, g)
+			}
+		}
+		return out.print(result, nil)
+	},
+}
+
+// parseGroupSetting parses a group set argument, <group>=<setting>[:...],
+// into the group reference and its control command.
+func parseGroupSetting(arg string) (string, at2plus.GroupControl, error) {
+	var ctrl at2plus.GroupControl
+	ref, settings, ok := strings.Cut(arg, "=")
+	if !ok || strings.TrimSpace(ref) == "" || settings == "" {
+		return "", ctrl, usagef("invalid setting %q: use <group>=<setting>[:<setting>...]", arg)
+	}
+	if err := checkUnitNumber("group", ref, 15); err != nil {
+		return "", ctrl, err
+	}
+
+	for _, s := range strings.Split(settings, ":") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, "%")); err == nil {
+			if n < 0 || n > 100 {
+				return "", ctrl, usagef("invalid percentage %d for %s: must be 0-100", n, ref)
+			}
+			if ctrl.Percent != nil || ctrl.Value != nil {
+				return "", ctrl, usagef("invalid setting %q: more than one value for %s", arg, ref)
+			}
+			ctrl.Percent = &n
+			continue
 		}
-		if acNum < 0 || acNum > 7 {
-			fmt.Printf("Invalid AC number %d: must be 0-7\n", acNum)
-			os.Exit(1)
+		if s == "inc" || s == "dec" {
+			if ctrl.Percent != nil || ctrl.Value != nil {
+				return "", ctrl, usagef("invalid setting %q: more than one value for %s", arg, ref)
+			}
+			v, _ := at2plus.ParseGroupValue(s)
+			ctrl.Value = &v
+			continue
+		}
+		p, err := at2plus.ParseGroupPowerCommand(s)
+		if err != nil || !slices.Contains(groupPowerChoices, p) {
+			return "", ctrl, usagef("invalid setting %q for %s (valid: %s, 0-100, inc, dec)", s, ref, strings.Join(choiceNames(groupPowerChoices), ", "))
+		}
+		if ctrl.Power != nil {
+			return "", ctrl, usagef("invalid setting %q: more than one power state for %s", arg, ref)
+		}
+		ctrl.Power = &p
+	}
+	return ref, ctrl, nil
+}
+
+var controlACCmd = &cobra.Command{
+	Use:     "ac <ac>",
+	Aliases: []string{"control-ac"},
+	Short:   "Control an AC, given by number or name",
+	Example: `  at2plus ac daikin --mode cool --temp 24
+  at2plus ac 0 --power off`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkUnitNumber("AC", args[0], 7); err != nil {
+			return err
 		}
 
 		powerStr, _ := cmd.Flags().GetString("power")
 		modeStr, _ := cmd.Flags().GetString("mode")
-		temp, _ := cmd.Flags().GetInt("temp")
+		fanStr, _ := cmd.Flags().GetString("fan")
+		tempStr, _ := cmd.Flags().GetString("temp")
 
-		var power *int
+		var power *at2plus.ACPowerCommand
 		if powerStr != "" {
-			p := 1 // Toggle
-			if powerStr == "off" {
-				p = 2
-			}
-			if powerStr == "on" {
-				p = 3
+			p, err := parseChoice("power", powerStr, at2plus.ParseACPowerCommand, acPowerChoices)
+			if err != nil {
+				return err
 			}
 			power = &p
 		}
 
-		var mode *int
+		var mode *at2plus.ACMode
 		if modeStr != "" {
-			m := 0 // Auto
-			if modeStr == "heat" {
-				m = 1
-			}
-			if modeStr == "dry" {
-				m = 2
-			}
-			if modeStr == "fan" {
-				m = 3
-			}
-			if modeStr == "cool" {
-				m = 4
+			m, err := parseChoice("mode", modeStr, at2plus.ParseACMode, acModeChoices)
+			if err != nil {
+				return err
 			}
 			mode = &m
 		}
 
-		var setpoint *int
-		if cmd.Flags().Changed("temp") {
-			setpoint = &temp
+		var fan *at2plus.FanSpeed
+		if fanStr != "" {
+			f, err := parseChoice("fan", fanStr, at2plus.ParseFanSpeed, fanSpeedChoices)
+			if err != nil {
+				return err
+			}
+			fan = &f
+		}
+
+		var setpoint *at2plus.Temperature
+		if tempStr != "" {
+			t, err := at2plus.ParseTemperature(tempStr, unit)
+			if err != nil {
+				return usagef("invalid temperature: %v", err)
+			}
+			if t < at2plus.MinSetpoint || t > at2plus.MaxSetpoint {
+				return usagef("invalid temperature %s: must be %s-%s", tempStr, at2plus.MinSetpoint, at2plus.MaxSetpoint)
+			}
+			setpoint = &t
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		client := getClient(ctx)
+		validation := at2plus.ValidateReject
+		if clamp, _ := cmd.Flags().GetBool("clamp"); clamp {
+			validation = at2plus.ValidateClamp
+		}
+
+		client, err := getClient(ctx, append(confirmOptions(cmd), at2plus.WithValidation(validation))...)
+		if err != nil {
+			return err
+		}
 		defer client.Close()
 
+		acNum, err := client.ResolveAC(ctx, args[0])
+		if err != nil {
+			return nameError(err)
+		}
+
 		err = client.SetACControl(ctx, []at2plus.ACControl{
 			{
-				ACNumber: uint8(acNum),
+				ACNumber: acNum,
 				Power:    power,
 				Mode:     mode,
+				FanSpeed: fan,
 				Setpoint: setpoint,
 			},
 		})
-
 		if err != nil {
-			fmt.Printf("Error controlling AC: %v\n", err)
-		} else {
+			return err
+		}
+		if !out.structured() {
 			fmt.Println("Command sent successfully.")
+			return nil
+		}
+
+		// Report the state the AC is in now
+		acs, err := client.GetACStatus(ctx)
+		if err != nil {
+			return err
 		}
+		for _, ac := range acs {
+			if ac.ACNumber == acNum {
+				return out.print(ac, nil)
+			}
+		}
+		return fmt.Errorf("AC %d not found", acNum)
+	},
+}
+
+var errorsCmd = &cobra.Command{
+	Use:   "errors",
+	Short: "Show error information of all ACs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := getClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		acs, err := client.GetACStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		errs := make([]at2plus.ACError, 0, len(acs))
+		var warnings []string
+		for _, ac := range acs {
+			acErr, err := client.GetACError(ctx, ac.ACNumber)
+			if err != nil {
+				return err
+			}
+			if acErr.Code != 0 && acErr.Code != ac.ErrorCode {
+				warnings = append(warnings, fmt.Sprintf("AC %d: error info code 0x%04X differs from status code 0x%04X", ac.ACNumber, acErr.Code, ac.ErrorCode))
+			}
+			errs = append(errs, acErr)
+		}
+
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning:", w)
+		}
+		return out.print(errs, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "AC\tCODE\tINFO")
+			for _, e := range errs {
+				if e.Code == 0 && e.Message == "" {
+					fmt.Fprintf(w, "%d\t-\tNo error\n", e.ACNumber)
+					continue
+				}
+				fmt.Fprintf(w, "%d\t0x%04X\t%s\n", e.ACNumber, e.Code, e.Message)
+			}
+		})
+	},
+}
+
+var abilitiesCmd = &cobra.Command{
+	Use:   "abilities [ac]",
+	Short: "Show what each AC supports: modes, fan speeds and setpoint ranges",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var only string
+		if len(args) == 1 {
+			if err := checkUnitNumber("AC", args[0], 7); err != nil {
+				return err
+			}
+			only = args[0]
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := getClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		abilities, err := acAbilities(ctx, client, only)
+		if err != nil {
+			return err
+		}
+
+		return out.print(abilities, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "AC\tNAME\tGROUPS\tMODES\tFAN SPEEDS\tCOOL SET\tHEAT SET")
+			for _, ab := range abilities {
+				var modes, fans []string
+				for _, m := range acModeChoices {
+					if ab.SupportsMode(m) {
+						modes = append(modes, m.String())
+					}
+				}
+				for _, f := range fanSpeedChoices {
+					if ab.SupportsFanSpeed(f) {
+						fans = append(fans, f.String())
+					}
+				}
+				groups := "-"
+				if ab.GroupCount > 0 {
+					groups = fmt.Sprintf("%d-%d", ab.StartGroup, int(ab.StartGroup)+int(ab.GroupCount)-1)
+				}
+				coolLo, coolHi := ab.SetpointRange(at2plus.Ptr(at2plus.ACModeCool))
+				heatLo, heatHi := ab.SetpointRange(at2plus.Ptr(at2plus.ACModeHeat))
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s-%s\t%s-%s\n", ab.ACNumber, ab.Name, groups,
+					strings.Join(modes, ","), strings.Join(fans, ","),
+					coolLo.Format(unit), coolHi.Format(unit), heatLo.Format(unit), heatHi.Format(unit))
+			}
+		})
+	},
+}
+
+// acAbilities returns the abilities of every AC, or only of the AC named
+// by only if it is set.
+func acAbilities(ctx context.Context, client *at2plus.Client, only string) ([]at2plus.ACAbility, error) {
+	var nums []uint8
+	if only != "" {
+		n, err := client.ResolveAC(ctx, only)
+		if err != nil {
+			return nil, nameError(err)
+		}
+		nums = []uint8{n}
+	} else {
+		acs, err := client.GetACStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ac := range acs {
+			nums = append(nums, ac.ACNumber)
+		}
+	}
+
+	abilities := make([]at2plus.ACAbility, 0, len(nums))
+	for _, n := range nums {
+		res, err := client.GetACAbility(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, ab := range res {
+			if ab.ACNumber == n {
+				abilities = append(abilities, ab)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("AC %d not found", n)
+		}
+	}
+	return abilities, nil
+}
+
+// namesResult is the output of the names command.
+type namesResult struct {
+	Groups []at2plus.GroupName `json:"groups"`
+	ACs    []acName            `json:"acs"`
+}
+
+// acName is the name of an AC, from its abilities.
+type acName struct {
+	ACNumber uint8  `json:"ac"`
+	Name     string `json:"name"`
+}
+
+var namesCmd = &cobra.Command{
+	Use:   "names",
+	Short: "Show the names of groups and ACs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := getClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		var res namesResult
+		if res.Groups, err = client.GetGroupNames(ctx); err != nil {
+			return err
+		}
+		abilities, err := acAbilities(ctx, client, "")
+		if err != nil {
+			return err
+		}
+		res.ACs = make([]acName, 0, len(abilities))
+		for _, ab := range abilities {
+			res.ACs = append(res.ACs, acName{ACNumber: ab.ACNumber, Name: ab.Name})
+		}
+
+		return out.print(res, func(w *tabwriter.Writer) {
+			fmt.Fprintln(w, "GROUP\tNAME")
+			for _, g := range res.Groups {
+				fmt.Fprintf(w, "%d\t%s\n", g.GroupNumber, g.Name)
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "AC\tNAME")
+			for _, ac := range res.ACs {
+				fmt.Fprintf(w, "%d\t%s\n", ac.ACNumber, ac.Name)
+			}
+		})
 	},
 }
 
 func init() {
-	controlGroupCmd.Flags().String("power", "", "Power state (on, off, turbo)")
+	discoverCmd.Flags().Bool("no-verify", false, "Report any host with the port open, without a protocol handshake")
+	discoverCmd.Flags().StringSlice("target", nil, "IP, CIDR or range (a.b.c.d-e.f.g.h) to scan instead of the local networks")
+	discoverCmd.Flags().StringSlice("iface", nil, "Only scan the networks of these interfaces")
+	discoverCmd.Flags().IntSlice("port", nil, "TCP ports to probe (default 9200)")
+	discoverCmd.Flags().Duration("timeout", 30*time.Second, "Overall discovery timeout")
+	discoverCmd.Flags().Duration("dial-timeout", at2plus.DefaultDiscoverDialTimeout, "Timeout for each connection attempt")
+	discoverCmd.Flags().Int("workers", at2plus.DefaultDiscoverWorkers, "Number of hosts probed at the same time")
+	discoverCmd.Flags().Bool("save", false, "Save the units found in the device registry")
+	discoverCmd.Flags().String("alias", "", "Alias to save the unit under (with --save, when one unit is found)")
+	discoverCmd.Flags().Bool("no-broadcast", false, "Do not send a UDP discovery broadcast")
+	discoverCmd.Flags().StringSlice("broadcast-addr", nil, "Send the UDP discovery broadcast to these host:port addresses")
+	discoverCmd.Flags().Duration("broadcast-wait", at2plus.DefaultBroadcastWait, "How long to wait for broadcast replies")
+
+	controlGroupCmd.Flags().String("power", "", "Power state ("+strings.Join(choiceNames(groupPowerChoices), ", ")+")")
 	controlGroupCmd.Flags().Int("percent", 0, "Open percentage (0-100)")
+	controlGroupCmd.Flags().Bool("inc", false, "Open the damper 5% more")
+	controlGroupCmd.Flags().Bool("dec", false, "Close the damper 5% more")
+	controlGroupCmd.PersistentFlags().Duration("confirm", 0, "Wait up to this long for the unit to report the change, e.g. 10s; fail if it does not")
+	controlGroupCmd.MarkFlagsMutuallyExclusive("percent", "inc", "dec")
+	controlGroupCmd.MarkFlagsOneRequired("power", "percent", "inc", "dec")
+	completeFlag(controlGroupCmd, "power", choiceNames(groupPowerChoices))
+
+	controlACCmd.Flags().String("power", "", "Power state ("+strings.Join(choiceNames(acPowerChoices), ", ")+")")
+	controlACCmd.Flags().String("mode", "", "Mode ("+strings.Join(choiceNames(acModeChoices), ", ")+")")
+	controlACCmd.Flags().String("fan", "", "Fan speed ("+strings.Join(choiceNames(fanSpeedChoices), ", ")+")")
+	controlACCmd.Flags().String("temp", "", "Temperature setpoint, e.g. 22.5 (in --unit, or with a C/F suffix)")
+	controlACCmd.Flags().Bool("clamp", false, "Clamp the setpoint to the AC's range instead of rejecting it")
+	controlACCmd.Flags().Duration("confirm", 0, "Wait up to this long for the unit to report the change, e.g. 10s; fail if it does not")
+	controlACCmd.MarkFlagsOneRequired("power", "mode", "fan", "temp")
+	completeFlag(controlACCmd, "power", choiceNames(acPowerChoices))
+	completeFlag(controlACCmd, "mode", choiceNames(acModeChoices))
+	completeFlag(controlACCmd, "fan", choiceNames(fanSpeedChoices))
+
+	controlGroupCmd.ValidArgsFunction = cobra.NoFileCompletions
+	controlACCmd.ValidArgsFunction = cobra.NoFileCompletions
+	abilitiesCmd.ValidArgsFunction = cobra.NoFileCompletions
+}
 
-	controlACCmd.Flags().String("power", "", "Power state (on, off)")
-	controlACCmd.Flags().String("mode", "", "Mode (auto, heat, dry, fan, cool)")
-	controlACCmd.Flags().Int("temp", 0, "Temperature setpoint")
+// notef prints progress and informational messages. They go to stderr
+// when results are printed as data, to keep stdout parseable.
+func notef(format string, args ...any) {
+	w := os.Stdout
+	if out.structured() {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format, args...)
 }
 
-func getClient(ctx context.Context) *at2plus.Client {
+// confirmOptions returns the client options for the --confirm flag.
+func confirmOptions(cmd *cobra.Command) []at2plus.ClientOption {
+	if d, _ := cmd.Flags().GetDuration("confirm"); d > 0 {
+		return []at2plus.ClientOption{at2plus.WithConfirm(d)}
+	}
+	return nil
+}
+
+// checkUnitNumber rejects a group or AC argument that is a number out of
+// the range 0-max. Anything that is not a number is a name, resolved once
+// connected.
+func checkUnitNumber(kind, arg string, max int) error {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil
+	}
+	if n < 0 || n > max {
+		return usagef("invalid %s number %d: must be 0-%d", kind, n, max)
+	}
+	return nil
+}
+
+// nameError makes a group or AC name that matches nothing, or more than
+// one unit, a usage error.
+func nameError(err error) error {
+	var ambiguous *at2plus.AmbiguousNameError
+	if errors.Is(err, at2plus.ErrUnknownName) {
+		return usageError{fmt.Errorf("%w; see the names command", err)}
+	}
+	if errors.As(err, &ambiguous) {
+		return usageError{err}
+	}
+	return err
+}
+
+func getClient(ctx context.Context, opts ...at2plus.ClientOption) (*at2plus.Client, error) {
+	if deviceFlag != "" {
+		if targetIP != "" {
+			return nil, usagef("use either --ip or --device, not both")
+		}
+		client, err := registryClient(ctx, deviceFlag, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("connect to %s: %w", deviceFlag, err)
+		}
+		return client, nil
+	}
 	if targetIP == "" {
-		fmt.Println("IP address required. Use --ip flag or run discover first.")
-		os.Exit(1)
+		return nil, usagef("IP address required: use the --ip or --device flag, or run discover --save first")
 	}
 
-	client, err := at2plus.NewClient(ctx, targetIP)
+	client, err := at2plus.NewClient(ctx, targetIP, opts...)
 	if err != nil {
-		fmt.Printf("Error connecting to %s: %v\n", targetIP, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("connect to %s: %w", targetIP, err)
 	}
-	return client
+	return client, nil
 }
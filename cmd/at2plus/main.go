@@ -10,12 +10,39 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "at2plus",
 	Short: "AirTouch 2+ Control CLI",
-	Long:  `A command line interface for controlling AirTouch 2+ air conditioner controllers.`,
+	Long: `A command line interface for controlling AirTouch 2+ air conditioner controllers.
+
+Exit status is 0 on success, 1 if a command fails and 2 for invalid
+flags or arguments.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+// started is set once cobra has accepted the command line and a command
+// runs. Errors before that are usage errors.
+var started bool
+
+// markStarted wraps the RunE of cmd and its subcommands to set started.
+func markStarted(cmd *cobra.Command) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			started = true
+			return run(cmd, args)
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markStarted(sub)
+	}
 }
 
 func main() {
+	markStarted(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		if !started {
+			// Rejected by cobra: unknown command or flag, wrong arguments
+			os.Exit(exitUsage)
+		}
+		os.Exit(exitCode(err))
 	}
 }
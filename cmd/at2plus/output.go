@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with --output.
+const (
+	outputTable    = "table"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputTemplate = "template"
+)
+
+// Exit codes.
+const (
+	exitFailure = 1 // the command failed, e.g. the device did not answer
+	exitUsage   = 2 // invalid flags or arguments
+)
+
+// usageError is an error in the command line rather than in running it.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// usagef returns a usageError with a formatted message.
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the process exit code for an error returned by a
+// command.
+func exitCode(err error) int {
+	var ue usageError
+	if errors.As(err, &ue) {
+		return exitUsage
+	}
+	return exitFailure
+}
+
+// printer renders command results in the format chosen with --output.
+// The structured formats all encode the JSON schema of the result, so
+// YAML keys and template fields use the JSON field names.
+type printer struct {
+	format string
+	tmpl   *template.Template
+	w      io.Writer
+}
+
+// newPrinter parses an --output value: table, json, yaml or
+// template=<Go text/template>.
+func newPrinter(spec string, w io.Writer) (*printer, error) {
+	p := &printer{format: spec, w: w}
+	switch spec {
+	case "", outputTable:
+		p.format = outputTable
+	case outputJSON, outputYAML:
+	default:
+		text, ok := strings.CutPrefix(spec, outputTemplate+"=")
+		if !ok {
+			return nil, usagef("invalid output format %q: use table, json, yaml or template=<template>", spec)
+		}
+		tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, usagef("invalid output template: %v", err)
+		}
+		p.format, p.tmpl = outputTemplate, tmpl
+	}
+	return p, nil
+}
+
+// structured reports whether results are printed as data rather than as
+// text for people.
+func (p *printer) structured() bool {
+	return p.format != outputTable
+}
+
+// print renders v, or calls table to print it as text in table format.
+func (p *printer) print(v any, table func(w *tabwriter.Writer)) error {
+	if p.format == outputTable {
+		tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	switch p.format {
+	case outputJSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err = buf.WriteTo(p.w)
+		return err
+
+	case outputYAML:
+		// JSON is YAML; decoding into a node keeps the field order
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return err
+		}
+		blockStyle(&node)
+		enc := yaml.NewEncoder(p.w)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return err
+		}
+		return enc.Close()
+
+	default:
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		return p.tmpl.Execute(p.w, doc)
+	}
+}
+
+// yaml11Bools are strings that YAML 1.1 parsers read as booleans. The
+// encoder leaves them unquoted, but "on" and "off" are common values here.
+var yaml11Bools = map[string]bool{
+	"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true,
+}
+
+// blockStyle makes n and its children print in plain YAML style rather
+// than the JSON-like style they were parsed in. Strings that would read
+// as another type stay quoted.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" && yaml11Bools[strings.ToLower(n.Value)] {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// out is the printer of the running command, set up from --output.
+var out = &printer{format: outputTable, w: os.Stdout}
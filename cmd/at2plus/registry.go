@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zberg/go-at2plus/pkg/at2plus"
+)
+
+// registryFile is the device registry, relative to the user config
+// directory ($XDG_CONFIG_HOME or ~/.config on Linux).
+const registryFile = "at2plus/devices.json"
+
+// registryDevice is a unit saved by discover --save.
+type registryDevice struct {
+	Alias     string    `json:"alias"`
+	DeviceID  string    `json:"device_id,omitempty"`
+	MAC       string    `json:"mac,omitempty"`
+	ConsoleID string    `json:"console_id,omitempty"`
+	IP        string    `json:"ip"`
+	Port      int       `json:"port,omitempty"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// registry is the set of saved units.
+type registry struct {
+	Devices []*registryDevice `json:"devices"`
+}
+
+// registryPath returns the path of the registry file.
+func registryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, registryFile), nil
+}
+
+// loadRegistry reads the registry. A missing file is an empty registry.
+func loadRegistry() (*registry, error) {
+	path, err := registryPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &registry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// save writes the registry, replacing the file atomically.
+func (r *registry) save() error {
+	path, err := registryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".devices-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// find returns the device with the given alias, or nil.
+func (r *registry) find(alias string) *registryDevice {
+	for _, d := range r.Devices {
+		if strings.EqualFold(d.Alias, alias) {
+			return d
+		}
+	}
+	return nil
+}
+
+// remove deletes the device with the given alias and reports whether it
+// was found.
+func (r *registry) remove(alias string) bool {
+	for i, d := range r.Devices {
+		if strings.EqualFold(d.Alias, alias) {
+			r.Devices = append(r.Devices[:i], r.Devices[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// upsert records a discovered unit. A unit already saved, matched by
+// device ID or else by IP, keeps its alias unless alias is set; a new
+// one gets alias or a name derived from the unit.
+func (r *registry) upsert(res at2plus.DiscoveryResult, alias string) (*registryDevice, error) {
+	var dev *registryDevice
+	for _, d := range r.Devices {
+		if (res.DeviceID != "" && d.DeviceID == res.DeviceID) || (res.DeviceID == "" && d.IP == res.IP) {
+			dev = d
+			break
+		}
+	}
+	if alias != "" {
+		if other := r.find(alias); other != nil && other != dev {
+			return nil, fmt.Errorf("alias %q is already used by %s", alias, other.IP)
+		}
+	}
+
+	if dev == nil {
+		dev = &registryDevice{}
+		r.Devices = append(r.Devices, dev)
+		if alias == "" {
+			alias = r.uniqueAlias(defaultAlias(res))
+		}
+	}
+	if alias != "" {
+		dev.Alias = alias
+	}
+	dev.DeviceID = res.DeviceID
+	dev.MAC = res.MAC
+	dev.ConsoleID = res.ConsoleID
+	dev.IP = res.IP
+	dev.Port = res.Port
+	dev.LastSeen = time.Now().UTC().Truncate(time.Second)
+	return dev, nil
+}
+
+// uniqueAlias returns base, or base with a number appended if it is taken.
+func (r *registry) uniqueAlias(base string) string {
+	alias := base
+	for n := 2; r.find(alias) != nil; n++ {
+		alias = fmt.Sprintf("%s-%d", base, n)
+	}
+	return alias
+}
+
+var (
+	aliasUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+	aliasValid  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// checkAlias rejects aliases that are awkward to type in a shell.
+func checkAlias(alias string) error {
+	if !aliasValid.MatchString(alias) {
+		return fmt.Errorf("invalid alias %q: use letters, digits, '-' and '_'", alias)
+	}
+	return nil
+}
+
+// defaultAlias derives an alias from the system name or the first AC name.
+func defaultAlias(res at2plus.DiscoveryResult) string {
+	name := res.Name
+	if name == "" && len(res.ACNames) > 0 {
+		name = res.ACNames[0]
+	}
+	alias := strings.Trim(aliasUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if alias == "" {
+		alias = "airtouch"
+	}
+	return alias
+}
+
+// Timeouts of connecting to a saved unit.
+const (
+	registryDialTimeout    = 3 * time.Second
+	registryResolveTimeout = 10 * time.Second
+)
+
+// registryClient connects to the saved unit with the given alias. If it
+// does not answer at its last known address it is searched for on the
+// network by device ID, and the registry is updated with its new address.
+func registryClient(ctx context.Context, alias string, opts ...at2plus.ClientOption) (*at2plus.Client, error) {
+	reg, err := loadRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("load device registry: %w", err)
+	}
+	dev := reg.find(alias)
+	if dev == nil {
+		return nil, fmt.Errorf("unknown device %q; run discover --save or see the devices command", alias)
+	}
+
+	port := dev.Port
+	if port == 0 {
+		port = 9200
+	}
+	opts = append([]at2plus.ClientOption{at2plus.WithPort(port)}, opts...)
+
+	dialCtx, cancel := context.WithTimeout(ctx, registryDialTimeout)
+	client, err := at2plus.NewClient(dialCtx, dev.IP, opts...)
+	cancel()
+	if err != nil {
+		if dev.DeviceID == "" {
+			return nil, fmt.Errorf("%s does not answer at %s: %w", dev.Alias, dev.IP, err)
+		}
+		fmt.Fprintf(os.Stderr, "%s does not answer at %s, searching the network...\n", dev.Alias, dev.IP)
+
+		resolveCtx, cancel := context.WithTimeout(ctx, registryResolveTimeout)
+		res, rerr := at2plus.ResolveDevice(resolveCtx, dev.DeviceID, at2plus.WithPorts(port))
+		cancel()
+		if rerr != nil {
+			return nil, fmt.Errorf("%s does not answer at %s: %w", dev.Alias, dev.IP, rerr)
+		}
+		client, err = at2plus.NewClient(ctx, res.IP, opts...)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "%s found at %s\n", dev.Alias, res.IP)
+		dev.IP = res.IP
+	}
+
+	dev.LastSeen = time.Now().UTC().Truncate(time.Second)
+	if err := reg.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update device registry: %v\n", err)
+	}
+	return client, nil
+}
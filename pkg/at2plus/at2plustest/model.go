@@ -0,0 +1,155 @@
+package at2plustest
+
+import (
+	"github.com/zberg/go-at2plus/pkg/at2plus"
+)
+
+// The *Locked helpers below require s.mu to be held.
+
+func (s *Server) groupStatusLocked() []byte {
+	statuses := make([]at2plus.GroupStatus, len(s.groups))
+	for i, g := range s.groups {
+		statuses[i] = g.Status
+	}
+	data, _ := at2plus.MarshalGroupStatus(statuses)
+	return data
+}
+
+func (s *Server) acStatusLocked() []byte {
+	statuses := make([]at2plus.ACStatus, len(s.acs))
+	for i, ac := range s.acs {
+		statuses[i] = ac.Status
+	}
+	data, _ := at2plus.MarshalACStatus(statuses)
+	return data
+}
+
+func (s *Server) acAbilityLocked(target *uint8) []byte {
+	var abilities []at2plus.ACAbility
+	for _, ac := range s.acs {
+		if target != nil && ac.Status.ACNumber != *target {
+			continue
+		}
+		a := ac.Ability
+		a.ACNumber = ac.Status.ACNumber
+		abilities = append(abilities, a)
+	}
+	data, _ := at2plus.MarshalACAbility(abilities)
+	return data
+}
+
+func (s *Server) groupNameLocked(target *uint8) []byte {
+	var names []at2plus.GroupName
+	for _, g := range s.groups {
+		if target != nil && g.Status.GroupNumber != *target {
+			continue
+		}
+		names = append(names, at2plus.GroupName{GroupNumber: g.Status.GroupNumber, Name: g.Name})
+	}
+	data, _ := at2plus.MarshalGroupName(names)
+	return data
+}
+
+func (s *Server) acErrorLocked(num uint8) []byte {
+	e := at2plus.ACError{ACNumber: num}
+	if ac := s.findAC(num); ac != nil {
+		e.Message = ac.Error
+	}
+	data, _ := at2plus.MarshalACError(e)
+	return data
+}
+
+// applyGroupControlLocked applies a 0x20 group control message.
+func (s *Server) applyGroupControlLocked(data []byte) {
+	controls, err := at2plus.UnmarshalGroupControl(data)
+	if err != nil {
+		return
+	}
+
+	for _, c := range controls {
+		g := s.findGroup(c.GroupNumber)
+		if g == nil {
+			continue
+		}
+		st := &g.Status
+
+		if c.Value != nil {
+			switch *c.Value {
+			case at2plus.GroupValueDecrease:
+				st.Percent = max(st.Percent-5, 0)
+			case at2plus.GroupValueIncrease:
+				st.Percent = min(st.Percent+5, 100)
+			case at2plus.GroupValueSet:
+				if c.Percent != nil {
+					st.Percent = *c.Percent
+				}
+			}
+		}
+
+		if c.Power != nil {
+			switch *c.Power {
+			case at2plus.GroupPowerNext: // off -> on -> turbo (if supported) -> off
+				switch {
+				case st.Power == at2plus.GroupPowerStateOff:
+					st.Power = at2plus.GroupPowerStateOn
+				case st.Power == at2plus.GroupPowerStateOn && st.TurboSupport:
+					st.Power = at2plus.GroupPowerStateTurbo
+				default:
+					st.Power = at2plus.GroupPowerStateOff
+				}
+			case at2plus.GroupPowerOff:
+				st.Power = at2plus.GroupPowerStateOff
+			case at2plus.GroupPowerOn:
+				st.Power = at2plus.GroupPowerStateOn
+			case at2plus.GroupPowerTurbo:
+				if st.TurboSupport {
+					st.Power = at2plus.GroupPowerStateTurbo
+				}
+			}
+		}
+	}
+}
+
+// applyACControlLocked applies a 0x22 AC control message.
+func (s *Server) applyACControlLocked(data []byte) {
+	controls, err := at2plus.UnmarshalACControl(data)
+	if err != nil {
+		return
+	}
+
+	for _, c := range controls {
+		ac := s.findAC(c.ACNumber)
+		if ac == nil {
+			continue
+		}
+		st := &ac.Status
+
+		if c.Power != nil {
+			switch *c.Power {
+			case at2plus.ACPowerToggle:
+				if st.Power.IsOn() {
+					st.Power = at2plus.ACPowerStateOff
+				} else {
+					st.Power = at2plus.ACPowerStateOn
+				}
+			case at2plus.ACPowerOff:
+				st.Power = at2plus.ACPowerStateOff
+			case at2plus.ACPowerOn:
+				st.Power = at2plus.ACPowerStateOn
+			case at2plus.ACPowerAway:
+				st.Power = at2plus.ACPowerStateAwayOn
+			case at2plus.ACPowerSleep:
+				st.Power = at2plus.ACPowerStateSleep
+			}
+		}
+		if c.Mode != nil {
+			st.Mode = *c.Mode
+		}
+		if c.FanSpeed != nil {
+			st.FanSpeed = *c.FanSpeed
+		}
+		if c.Setpoint != nil {
+			st.Setpoint = *c.Setpoint
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Package at2plustest provides an in-process AirTouch 2+ emulator for
+// testing code that uses package at2plus without real hardware.
+//
+// The Server speaks the AirTouch 2+ TCP protocol on a loopback port, keeps
+// an in-memory model of groups and ACs, answers status and extended
+// queries, applies control commands and can push unsolicited status
+// updates the way a real console does when someone uses the wall panel.
+//
+//	srv, err := at2plustest.NewServer(
+//	    []at2plustest.Group{{Status: at2plus.GroupStatus{GroupNumber: 0}, Name: "Living"}},
+//	    []at2plustest.AC{{Status: at2plus.ACStatus{ACNumber: 0}}},
+//	)
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	defer srv.Close()
+//
+//	client, err := at2plus.NewClient(ctx, srv.IP(), at2plus.WithPort(srv.Port()))
+package at2plustest
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/zberg/go-at2plus/pkg/at2plus"
+)
+
+// Group is the emulated state of one zone.
+type Group struct {
+	Status at2plus.GroupStatus
+	Name   string
+}
+
+// AC is the emulated state of one AC unit.
+type AC struct {
+	Status  at2plus.ACStatus
+	Ability at2plus.ACAbility
+	Error   string // Error info returned by the 0x10 query, e.g. "ER: FFFE"
+}
+
+// Server is an emulated AirTouch 2+ console listening on loopback.
+type Server struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	groups []Group
+	acs    []AC
+	conns  map[net.Conn]*sync.Mutex
+	udp    []net.PacketConn
+	wg     sync.WaitGroup
+	closed bool
+
+	onRequest func(*at2plus.Packet)
+}
+
+// NewServer starts an emulator with the given groups and ACs.
+// Group and AC numbers are taken from their Status fields.
+func NewServer(groups []Group, acs []AC) (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	s := &Server{
+		ln:     ln,
+		groups: append([]Group(nil), groups...),
+		acs:    append([]AC(nil), acs...),
+		conns:  make(map[net.Conn]*sync.Mutex),
+	}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// IP returns the IP address the server listens on.
+func (s *Server) IP() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the TCP port the server listens on.
+func (s *Server) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// ServeBroadcast answers discovery broadcasts on a loopback UDP port and
+// returns the port. Each request is answered with info; an empty IP is
+// replaced by the server's IP, so the reply leads to the emulated console.
+// Point discovery at it with at2plus.WithBroadcastAddrs.
+func (s *Server) ServeBroadcast(info at2plus.DiscoveryResult) (int, error) {
+	if info.IP == "" {
+		info.IP = s.IP()
+	}
+	reply, err := at2plus.MarshalBroadcastReply(info)
+	if err != nil {
+		return 0, err
+	}
+
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("listen: %w", err)
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		pc.Close()
+		return 0, net.ErrClosed
+	}
+	s.udp = append(s.udp, pc)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) != at2plus.BroadcastRequest {
+				continue
+			}
+			pc.WriteTo(reply, from)
+		}
+	}()
+	return pc.LocalAddr().(*net.UDPAddr).Port, nil
+}
+
+// Close stops the server and drops all client connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.ln.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	for _, pc := range s.udp {
+		pc.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Groups returns a snapshot of the emulated groups.
+func (s *Server) Groups() []Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Group(nil), s.groups...)
+}
+
+// ACs returns a snapshot of the emulated ACs.
+func (s *Server) ACs() []AC {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AC(nil), s.acs...)
+}
+
+// OnRequest sets a function called with each request before it is
+// answered, e.g. to change state while a client is midway through a series
+// of queries. It runs on the connection's goroutine and must not retain the
+// packet. Pass nil to remove it.
+func (s *Server) OnRequest(fn func(*at2plus.Packet)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRequest = fn
+}
+
+// UpdateGroup changes a group as if from the wall console and pushes the
+// new group status to every connected client.
+func (s *Server) UpdateGroup(num uint8, fn func(*Group)) error {
+	s.mu.Lock()
+	g := s.findGroup(num)
+	if g == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("group %d not found", num)
+	}
+	fn(g)
+	data := s.groupStatusLocked()
+	s.mu.Unlock()
+
+	s.push(nil, data)
+	return nil
+}
+
+// UpdateAC changes an AC as if from the wall console and pushes the new
+// AC status to every connected client.
+func (s *Server) UpdateAC(num uint8, fn func(*AC)) error {
+	s.mu.Lock()
+	ac := s.findAC(num)
+	if ac == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("AC %d not found", num)
+	}
+	fn(ac)
+	data := s.acStatusLocked()
+	s.mu.Unlock()
+
+	s.push(nil, data)
+	return nil
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = &sync.Mutex{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// serve handles one client connection until it is closed.
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	// The request packet is reused; handle does not retain it
+	framer := at2plus.NewFramer(conn)
+	p := &at2plus.Packet{}
+	for {
+		if err := framer.NextInto(p); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		onRequest := s.onRequest
+		s.mu.Unlock()
+		if onRequest != nil {
+			onRequest(p)
+		}
+
+		resp, changed := s.handle(p)
+		if resp == nil {
+			continue
+		}
+
+		addr := uint16(at2plus.AddressRecvStandard)
+		if p.MsgType == at2plus.MsgTypeExtended {
+			addr = at2plus.AddressRecvExtended
+		}
+		if err := s.send(conn, at2plus.NewPacket(addr, p.MsgID, p.MsgType, resp)); err != nil {
+			return
+		}
+
+		// A real console tells every other client about state changes too
+		if changed {
+			s.push(conn, resp)
+		}
+	}
+}
+
+// send writes a packet to one client, serialised with other writers.
+func (s *Server) send(conn net.Conn, p *at2plus.Packet) error {
+	s.mu.Lock()
+	wmu, ok := s.conns[conn]
+	s.mu.Unlock()
+	if !ok {
+		return net.ErrClosed
+	}
+
+	buf := frameBufs.Get().(*[]byte)
+	*buf = p.AppendEncode((*buf)[:0])
+	defer frameBufs.Put(buf)
+
+	wmu.Lock()
+	defer wmu.Unlock()
+	_, err := conn.Write(*buf)
+	return err
+}
+
+// frameBufs holds buffers for encoding outgoing packets.
+var frameBufs = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 64)
+		return &b
+	},
+}
+
+// push sends an unsolicited status message to every client except skip.
+// Pushed packets use message ID 0.
+func (s *Server) push(skip net.Conn, data []byte) {
+	s.mu.Lock()
+	conns := make([]net.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		if conn != skip {
+			conns = append(conns, conn)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, conn := range conns {
+		s.send(conn, at2plus.NewPacket(at2plus.AddressRecvStandard, 0, at2plus.MsgTypeControlStatus, data))
+	}
+}
+
+// handle answers one request. It returns the response data, or nil if the
+// request is ignored, and whether the request changed any state.
+func (s *Server) handle(p *at2plus.Packet) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch p.MsgType {
+	case at2plus.MsgTypeControlStatus:
+		if len(p.Data) < 8 {
+			return nil, false
+		}
+		switch p.Data[0] {
+		case at2plus.SubMsgTypeGroupStatus:
+			return s.groupStatusLocked(), false
+		case at2plus.SubMsgTypeACStatus:
+			return s.acStatusLocked(), false
+		case at2plus.SubMsgTypeGroupControl:
+			s.applyGroupControlLocked(p.Data)
+			return s.groupStatusLocked(), true
+		case at2plus.SubMsgTypeACControl:
+			s.applyACControlLocked(p.Data)
+			return s.acStatusLocked(), true
+		}
+
+	case at2plus.MsgTypeExtended:
+		if len(p.Data) < 2 || p.Data[0] != 0xFF {
+			return nil, false
+		}
+		var target *uint8
+		if len(p.Data) > 2 {
+			target = &p.Data[2]
+		}
+		switch p.Data[1] {
+		case at2plus.ExtMsgTypeACAbility:
+			return s.acAbilityLocked(target), false
+		case at2plus.ExtMsgTypeGroupName:
+			return s.groupNameLocked(target), false
+		case at2plus.ExtMsgTypeACError:
+			if target == nil {
+				return nil, false
+			}
+			return s.acErrorLocked(*target), false
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) findGroup(num uint8) *Group {
+	for i := range s.groups {
+		if s.groups[i].Status.GroupNumber == num {
+			return &s.groups[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) findAC(num uint8) *AC {
+	for i := range s.acs {
+		if s.acs[i].Status.ACNumber == num {
+			return &s.acs[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package at2plustest_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zberg/go-at2plus/pkg/at2plus"
+	"github.com/zberg/go-at2plus/pkg/at2plus/at2plustest"
+)
+
+func newServer(t *testing.T) *at2plustest.Server {
+	t.Helper()
+	srv, err := at2plustest.NewServer(
+		[]at2plustest.Group{
+			{Status: at2plus.GroupStatus{GroupNumber: 0, Power: at2plus.GroupPowerStateOn, Percent: 50, TurboSupport: true}, Name: "Living"},
+			{Status: at2plus.GroupStatus{GroupNumber: 1, Power: at2plus.GroupPowerStateOff, Percent: 0}, Name: "Kitchen"},
+		},
+		[]at2plustest.AC{
+			{
+				Status: at2plus.ACStatus{
+					ACNumber: 0, Power: at2plus.ACPowerStateOn, Mode: at2plus.ACModeHeat, FanSpeed: at2plus.FanSpeedLow,
+					Setpoint: at2plus.Celsius(22), Temperature: at2plus.Celsius(23.5),
+				},
+				Ability: at2plus.ACAbility{
+					Name: "UNIT", GroupCount: 2, CoolMode: true, HeatMode: true, FanAuto: true,
+					MinCoolSet: 17, MaxCoolSet: 31, MinHeatSet: 17, MaxHeatSet: 31,
+				},
+				Error: "ER: FFFE",
+			},
+		},
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func dial(t *testing.T, srv *at2plustest.Server) *at2plus.Client {
+	t.Helper()
+	client, err := at2plus.NewClient(context.Background(), srv.IP(), at2plus.WithPort(srv.Port()))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestServer_Status(t *testing.T) {
+	client := dial(t, newServer(t))
+	ctx := context.Background()
+
+	groups, err := client.GetGroupStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, at2plus.GroupPowerStateOn, groups[0].Power)
+	assert.Equal(t, 50, groups[0].Percent)
+	assert.True(t, groups[0].TurboSupport)
+
+	acs, err := client.GetACStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, acs, 1)
+	assert.Equal(t, at2plus.Celsius(22), acs[0].Setpoint)
+	assert.Equal(t, at2plus.Celsius(23.5), acs[0].Temperature)
+}
+
+func TestServer_GroupControl(t *testing.T) {
+	srv := newServer(t)
+	client := dial(t, srv)
+
+	err := client.SetGroupControl(context.Background(), []at2plus.GroupControl{
+		{GroupNumber: 1, Power: at2plus.Ptr(at2plus.GroupPowerOn), Percent: at2plus.Ptr(80)},
+	})
+	require.NoError(t, err)
+
+	g := srv.Groups()[1]
+	assert.Equal(t, at2plus.GroupPowerStateOn, g.Status.Power)
+	assert.Equal(t, 80, g.Status.Percent)
+}
+
+func TestServer_ACControl(t *testing.T) {
+	srv := newServer(t)
+	client := dial(t, srv)
+
+	err := client.SetACControl(context.Background(), []at2plus.ACControl{
+		{ACNumber: 0, Power: at2plus.Ptr(at2plus.ACPowerOff), Mode: at2plus.Ptr(at2plus.ACModeCool), Setpoint: at2plus.Ptr(at2plus.Celsius(24.5))},
+	})
+	require.NoError(t, err)
+
+	st := srv.ACs()[0].Status
+	assert.Equal(t, at2plus.ACPowerStateOff, st.Power)
+	assert.Equal(t, at2plus.ACModeCool, st.Mode)
+	assert.Equal(t, at2plus.FanSpeedLow, st.FanSpeed) // Unchanged
+	assert.Equal(t, at2plus.Celsius(24.5), st.Setpoint)
+}
+
+func TestServer_ControlByName(t *testing.T) {
+	srv := newServer(t)
+	client := dial(t, srv)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGroup(ctx, "kitchen", at2plus.GroupControl{Percent: at2plus.Ptr(50)}))
+	assert.Equal(t, 50, srv.Groups()[1].Status.Percent)
+
+	require.NoError(t, client.SetAC(ctx, "unit", at2plus.ACControl{Mode: at2plus.Ptr(at2plus.ACModeCool)}))
+	assert.Equal(t, at2plus.ACModeCool, srv.ACs()[0].Status.Mode)
+
+	_, err := client.ResolveAC(ctx, "daikin")
+	assert.ErrorIs(t, err, at2plus.ErrUnknownName)
+
+	// A group renamed on the console is found once the cached names miss
+	require.NoError(t, srv.UpdateGroup(0, func(g *at2plustest.Group) { g.Name = "Lounge" }))
+	num, err := client.ResolveGroup(ctx, "lounge")
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0), num)
+}
+
+func TestServer_ConfirmedControl(t *testing.T) {
+	srv := newServer(t)
+	client, err := at2plus.NewClient(context.Background(), srv.IP(), at2plus.WithPort(srv.Port()),
+		at2plus.WithConfirm(time.Second))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGroupControl(ctx, []at2plus.GroupControl{
+		{GroupNumber: 1, Power: at2plus.Ptr(at2plus.GroupPowerOn), Percent: at2plus.Ptr(70)},
+	}))
+	require.NoError(t, client.SetACControl(ctx, []at2plus.ACControl{
+		{ACNumber: 0, Power: at2plus.Ptr(at2plus.ACPowerOff), Mode: at2plus.Ptr(at2plus.ACModeCool), Setpoint: at2plus.Ptr(at2plus.Celsius(24))},
+	}))
+	assert.Equal(t, at2plus.ACPowerStateOff, srv.ACs()[0].Status.Power)
+}
+
+func TestServer_StateStore(t *testing.T) {
+	srv := newServer(t)
+	client := dial(t, srv)
+	ctx := context.Background()
+
+	store, err := at2plus.NewStateStore(ctx, client)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+
+	snap := store.Snapshot()
+	require.Len(t, snap.Groups, 2)
+	assert.Equal(t, "Kitchen", snap.Groups[1].Name)
+	require.NotNil(t, snap.Groups[1].AC)
+	assert.Equal(t, uint8(0), *snap.Groups[1].AC)
+	ac, ok := snap.AC(0)
+	require.True(t, ok)
+	assert.Equal(t, "UNIT", ac.Ability.Name)
+	assert.Equal(t, []uint8{0, 1}, ac.Groups)
+
+	events := store.Watch(ctx)
+	next := func() at2plus.Event {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event")
+			return nil
+		}
+	}
+
+	require.NoError(t, srv.UpdateGroup(0, func(g *at2plustest.Group) { g.Status.Power = at2plus.GroupPowerStateOff }))
+	ev, ok := next().(at2plus.GroupPowerChanged)
+	require.True(t, ok)
+	assert.Equal(t, uint8(0), ev.Group)
+	assert.Equal(t, at2plus.GroupPowerStateOn, ev.Old)
+	assert.Equal(t, at2plus.GroupPowerStateOff, ev.New)
+
+	require.NoError(t, srv.UpdateAC(0, func(ac *at2plustest.AC) { ac.Status.ErrorCode = 0xFFFE }))
+	raised, ok := next().(at2plus.ACErrorRaised)
+	require.True(t, ok)
+	assert.Equal(t, 0xFFFE, raised.Code)
+	assert.Equal(t, "ER: FFFE", raised.Message)
+
+	g, _ := store.Snapshot().Group(0)
+	assert.Equal(t, at2plus.GroupPowerStateOff, g.Power)
+	assert.True(t, g.Since["Power"].After(g.Since["Percent"]))
+
+	// A change pushed while Refresh polls is not undone by the older
+	// status Refresh polled before it
+	var (
+		once   sync.Once
+		pushed at2plus.Event
+	)
+	srv.OnRequest(func(p *at2plus.Packet) {
+		if p.MsgType != at2plus.MsgTypeExtended || p.Data[1] != at2plus.ExtMsgTypeGroupName {
+			return
+		}
+		once.Do(func() {
+			srv.UpdateGroup(1, func(g *at2plustest.Group) { g.Status.Percent = 30 })
+			select {
+			case pushed = <-events:
+			case <-time.After(2 * time.Second):
+			}
+		})
+	})
+	require.NoError(t, store.Refresh(ctx))
+	pct, ok := pushed.(at2plus.GroupPercentChanged)
+	require.True(t, ok, "pushed change not seen during refresh")
+	assert.Equal(t, 30, pct.New)
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %#v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+	g, _ = store.Snapshot().Group(1)
+	assert.Equal(t, 30, g.Percent)
+
+	store.Close()
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestServer_Extended(t *testing.T) {
+	client := dial(t, newServer(t))
+	ctx := context.Background()
+
+	abilities, err := client.GetACAbility(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, abilities, 1)
+	assert.Equal(t, "UNIT", abilities[0].Name)
+	assert.True(t, abilities[0].CoolMode)
+	assert.False(t, abilities[0].DryMode)
+	assert.Equal(t, 31, abilities[0].MaxHeatSet)
+
+	names, err := client.GetGroupNames(ctx)
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+	assert.Equal(t, "Kitchen", names[1].Name)
+
+	acErr, err := client.GetACError(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0xFFFE, acErr.Code)
+}
+
+func TestServer_PushUpdate(t *testing.T) {
+	srv := newServer(t)
+	client := dial(t, srv)
+
+	sub, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	// Make sure the connection is registered before pushing
+	_, err = client.GetGroupStatus(context.Background())
+	require.NoError(t, err)
+
+	err = srv.UpdateGroup(1, func(g *at2plustest.Group) { g.Status.Percent = 30 })
+	require.NoError(t, err)
+
+	select {
+	case u := <-sub.Updates():
+		require.Len(t, u.Groups, 2)
+		assert.Equal(t, 30, u.Groups[1].Percent)
+	case <-time.After(time.Second):
+		t.Fatal("no update pushed")
+	}
+}
+
+func TestServer_ControlPushedToOtherClients(t *testing.T) {
+	srv := newServer(t)
+	a := dial(t, srv)
+	b := dial(t, srv)
+
+	sub, err := b.Subscribe(context.Background())
+	require.NoError(t, err)
+	_, err = b.GetACStatus(context.Background())
+	require.NoError(t, err)
+
+	ctl := []at2plus.ACControl{{ACNumber: 0, Power: at2plus.Ptr(at2plus.ACPowerAway)}}
+	require.NoError(t, a.SetACControl(context.Background(), ctl))
+
+	select {
+	case u := <-sub.Updates():
+		require.Len(t, u.ACs, 1)
+		assert.Equal(t, at2plus.ACPowerStateAwayOn, u.ACs[0].Power)
+	case <-time.After(time.Second):
+		t.Fatal("no update pushed")
+	}
+}
+
+func TestServer_UpdateUnknown(t *testing.T) {
+	srv := newServer(t)
+
+	assert.Error(t, srv.UpdateGroup(9, func(*at2plustest.Group) {}))
+	assert.Error(t, srv.UpdateAC(9, func(*at2plustest.AC) {}))
+}
+
+func TestServer_Broadcast(t *testing.T) {
+	srv := newServer(t)
+	port, err := srv.ServeBroadcast(at2plus.DiscoveryResult{ConsoleID: "554B1234567", DeviceType: "AirTouch2+", AirTouchID: "12345678"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var found []at2plus.DiscoveryResult
+	err = at2plus.DiscoverEach(ctx, func(res at2plus.DiscoveryResult) {
+		found = append(found, res)
+		if res.Verified {
+			cancel()
+		}
+	},
+		at2plus.WithBroadcastAddrs(fmt.Sprintf("127.0.0.1:%d", port)),
+		at2plus.WithTargets(srv.IP()),
+		at2plus.WithPorts(srv.Port()),
+		at2plus.WithHandshake(),
+	)
+	require.NoError(t, err)
+	require.NotEmpty(t, found)
+
+	// The broadcast reply and the port scan are merged into one device
+	res := found[len(found)-1]
+	assert.Equal(t, srv.IP(), res.IP)
+	assert.Equal(t, srv.Port(), res.Port)
+	assert.Equal(t, "554B1234567", res.ConsoleID)
+	assert.Equal(t, "AirTouch2+", res.DeviceType)
+	assert.True(t, res.Verified)
+	assert.Equal(t, 2, res.GroupCount)
+	assert.Equal(t, []string{"UNIT"}, res.ACNames)
+}
+
+func TestServer_BroadcastOnly(t *testing.T) {
+	srv := newServer(t)
+	port, err := srv.ServeBroadcast(at2plus.DiscoveryResult{ConsoleID: "C1", DeviceType: "AirTouch2+"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	results, err := at2plus.Discover(ctx,
+		at2plus.WithBroadcastAddrs(fmt.Sprintf("127.0.0.1:%d", port)),
+		at2plus.WithoutPortScan(),
+		at2plus.WithPorts(srv.Port()),
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "C1", results[0].ConsoleID)
+	assert.Equal(t, srv.Port(), results[0].Port)
+	assert.False(t, results[0].Verified)
+}
+
+func TestServer_BroadcastEndsAfterWait(t *testing.T) {
+	srv := newServer(t)
+	port, err := srv.ServeBroadcast(at2plus.DiscoveryResult{ConsoleID: "C1", DeviceType: "AirTouch2+"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	results, err := at2plus.Discover(ctx,
+		at2plus.WithBroadcastAddrs(fmt.Sprintf("127.0.0.1:%d", port)),
+		at2plus.WithTargets(srv.IP()),
+		at2plus.WithPorts(srv.Port()),
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "C1", results[0].ConsoleID)
+	assert.Less(t, time.Since(start), at2plus.DefaultBroadcastWait+2*time.Second)
+}
+
+func TestServer_ConnectByDeviceID(t *testing.T) {
+	srv := newServer(t)
+	port, err := srv.ServeBroadcast(at2plus.DiscoveryResult{ConsoleID: "554B1234567", DeviceType: "AirTouch2+"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	client, err := at2plus.NewClient(ctx, "console:554B1234567",
+		at2plus.WithPort(srv.Port()),
+		at2plus.WithDiscoverOptions(
+			at2plus.WithBroadcastAddrs(fmt.Sprintf("127.0.0.1:%d", port)),
+			at2plus.WithTargets(srv.IP()),
+		),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	groups, err := client.GetGroupStatus(ctx)
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_, err = at2plus.NewClient(ctx, "console:unknown",
+		at2plus.WithPort(srv.Port()),
+		at2plus.WithDiscoverOptions(at2plus.WithBroadcastAddrs(fmt.Sprintf("127.0.0.1:%d", port)), at2plus.WithoutPortScan()),
+	)
+	assert.ErrorIs(t, err, at2plus.ErrDeviceNotFound)
+}
@@ -0,0 +1,287 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Batch collects group and AC control commands and sends them in as few
+// packets as possible. The control messages carry any number of groups or
+// ACs, so a batch normally needs one packet for its groups and one for its
+// ACs. Commands for the same group or AC are merged, later fields replacing
+// earlier ones.
+//
+// Relative commands (GroupPowerNext, GroupValueIncrease and
+// GroupValueDecrease, ACPowerToggle) cannot be merged with another command
+// for the same setting, since each must reach the device to take effect.
+// Such a command goes into a further packet, sent after the first.
+//
+// A Batch is not safe for concurrent use.
+type Batch struct {
+	client *Client
+	groups [][]GroupControl // packets in send order, one command per group each
+	acs    [][]ACControl    // packets in send order, one command per AC each
+}
+
+// Batch returns an empty batch of control commands for the client.
+func (c *Client) Batch() *Batch {
+	return &Batch{client: c}
+}
+
+// Group adds a group control command to the batch.
+func (b *Batch) Group(ctrl GroupControl) *Batch {
+	b.groups = addCommand(b.groups, ctrl, func(g GroupControl) uint8 { return g.GroupNumber }, mergeGroupControl)
+	return b
+}
+
+// AC adds an AC control command to the batch.
+func (b *Batch) AC(ctrl ACControl) *Batch {
+	b.acs = addCommand(b.acs, ctrl, func(ac ACControl) uint8 { return ac.ACNumber }, mergeACControl)
+	return b
+}
+
+// Packets returns the number of packets Send will write.
+func (b *Batch) Packets() int {
+	return len(b.groups) + len(b.acs)
+}
+
+// Send writes the commands of the batch to the device, group commands
+// first, and empties the batch. It stops at the first packet that fails.
+// AC commands are validated as by SetACControl.
+func (b *Batch) Send(ctx context.Context) error {
+	groups, acs := b.groups, b.acs
+	b.groups, b.acs = nil, nil
+
+	for _, p := range groups {
+		if err := b.client.SetGroupControl(ctx, p); err != nil {
+			return err
+		}
+	}
+	for _, p := range acs {
+		if err := b.client.SetACControl(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addCommand merges ctrl into the command for the same unit in the last
+// packet, or adds it to that packet if it has none. A command that cannot
+// be merged starts a new packet.
+func addCommand[T any](packets [][]T, ctrl T, num func(T) uint8, merge func(a, b T) (T, bool)) [][]T {
+	if len(packets) == 0 {
+		return [][]T{{ctrl}}
+	}
+	last := packets[len(packets)-1]
+	for i, prev := range last {
+		if num(prev) != num(ctrl) {
+			continue
+		}
+		if merged, ok := merge(prev, ctrl); ok {
+			last[i] = merged
+			return packets
+		}
+		return append(packets, []T{ctrl})
+	}
+	packets[len(packets)-1] = append(last, ctrl)
+	return packets
+}
+
+// mergeGroupControl applies b on top of a. It reports false if a relative
+// command would be lost.
+func mergeGroupControl(a, b GroupControl) (GroupControl, bool) {
+	if a.Power != nil && b.Power != nil {
+		if *a.Power == GroupPowerNext || *b.Power == GroupPowerNext {
+			return a, false
+		}
+	}
+	relative := func(g GroupControl) bool {
+		return g.Value != nil && (*g.Value == GroupValueIncrease || *g.Value == GroupValueDecrease)
+	}
+	aValue := a.Value != nil || a.Percent != nil
+	bValue := b.Value != nil || b.Percent != nil
+	if aValue && bValue && (relative(a) || relative(b)) {
+		return a, false
+	}
+
+	if b.Power != nil {
+		a.Power = b.Power
+	}
+	if bValue {
+		a.Value, a.Percent = b.Value, b.Percent
+	}
+	return a, true
+}
+
+// mergeACControl applies b on top of a. It reports false if a toggle
+// would be lost.
+func mergeACControl(a, b ACControl) (ACControl, bool) {
+	if a.Power != nil && b.Power != nil {
+		if *a.Power == ACPowerToggle || *b.Power == ACPowerToggle {
+			return a, false
+		}
+	}
+
+	if b.Power != nil {
+		a.Power = b.Power
+	}
+	if b.Mode != nil {
+		a.Mode = b.Mode
+	}
+	if b.FanSpeed != nil {
+		a.FanSpeed = b.FanSpeed
+	}
+	if b.Setpoint != nil {
+		a.Setpoint = b.Setpoint
+	}
+	return a, true
+}
+
+// CoalesceOption configures a Coalescer.
+type CoalesceOption func(*coalesceConfig) error
+
+// coalesceConfig holds the configuration for a Coalescer.
+type coalesceConfig struct {
+	maxDelay     time.Duration
+	errorHandler func(error)
+}
+
+// WithMaxDelay caps how long a change waits while further changes keep
+// arriving. Default is no cap: changes are sent once none has arrived for
+// the coalescing window.
+func WithMaxDelay(d time.Duration) CoalesceOption {
+	return func(c *coalesceConfig) error {
+		if d <= 0 {
+			return errors.New("max delay must be positive")
+		}
+		c.maxDelay = d
+		return nil
+	}
+}
+
+// WithErrorHandler sets a function called with the error of each write
+// the Coalescer makes on its own. It is called from the Coalescer's
+// goroutine and must not block.
+func WithErrorHandler(fn func(error)) CoalesceOption {
+	return func(c *coalesceConfig) error {
+		c.errorHandler = fn
+		return nil
+	}
+}
+
+// Coalescer debounces rapid successive control changes, such as those
+// from a slider in a UI, into one write. Changes are collected in a Batch
+// and sent once no further change has arrived for the coalescing window.
+//
+// A Coalescer is safe for concurrent use.
+type Coalescer struct {
+	client   *Client
+	window   time.Duration
+	maxDelay time.Duration
+	onError  func(error)
+
+	mu      sync.Mutex
+	pending *Batch
+	first   time.Time // when the first pending change arrived
+	timer   *time.Timer
+	closed  bool
+
+	sendMu sync.Mutex // keeps writes in the order of their changes
+}
+
+// Coalesce returns a Coalescer that sends the changes given to it once
+// they have been quiet for window.
+func (c *Client) Coalesce(window time.Duration, opts ...CoalesceOption) (*Coalescer, error) {
+	if window <= 0 {
+		return nil, errors.New("invalid option: coalescing window must be positive")
+	}
+	cfg := &coalesceConfig{}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, fmt.Errorf("invalid option: %w", err)
+		}
+	}
+	return &Coalescer{
+		client:   c,
+		window:   window,
+		maxDelay: cfg.maxDelay,
+		onError:  cfg.errorHandler,
+	}, nil
+}
+
+// Group queues a group control command. It returns ErrClosed once the
+// Coalescer is closed.
+func (co *Coalescer) Group(ctrl GroupControl) error {
+	return co.add(func(b *Batch) { b.Group(ctrl) })
+}
+
+// AC queues an AC control command. It returns ErrClosed once the
+// Coalescer is closed.
+func (co *Coalescer) AC(ctrl ACControl) error {
+	return co.add(func(b *Batch) { b.AC(ctrl) })
+}
+
+// add queues a change and restarts the coalescing window.
+func (co *Coalescer) add(fn func(*Batch)) error {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	if co.closed {
+		return fmt.Errorf("coalesce: %w", ErrClosed)
+	}
+
+	now := time.Now()
+	if co.pending == nil {
+		co.pending = co.client.Batch()
+		co.first = now
+	}
+	fn(co.pending)
+
+	delay := co.window
+	if co.maxDelay > 0 {
+		delay = max(0, min(delay, co.first.Add(co.maxDelay).Sub(now)))
+	}
+	if co.timer == nil {
+		co.timer = time.AfterFunc(delay, co.fire)
+	} else {
+		co.timer.Reset(delay)
+	}
+	return nil
+}
+
+// fire sends the pending changes when the coalescing window ends.
+func (co *Coalescer) fire() {
+	if err := co.Flush(context.Background()); err != nil && co.onError != nil {
+		co.onError(err)
+	}
+}
+
+// Flush sends the pending changes now.
+func (co *Coalescer) Flush(ctx context.Context) error {
+	co.sendMu.Lock()
+	defer co.sendMu.Unlock()
+
+	co.mu.Lock()
+	b := co.pending
+	co.pending = nil
+	if co.timer != nil {
+		co.timer.Stop()
+	}
+	co.mu.Unlock()
+
+	if b == nil {
+		return nil
+	}
+	return b.Send(ctx)
+}
+
+// Close sends the pending changes and stops the Coalescer. Later changes
+// are rejected with ErrClosed.
+func (co *Coalescer) Close() error {
+	co.mu.Lock()
+	co.closed = true
+	co.mu.Unlock()
+	return co.Flush(context.Background())
+}
@@ -0,0 +1,119 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch_Merge(t *testing.T) {
+	b := new(Client).Batch().
+		Group(GroupControl{GroupNumber: 1, Power: Ptr(GroupPowerOn)}).
+		Group(GroupControl{GroupNumber: 3, Power: Ptr(GroupPowerOff)}).
+		Group(GroupControl{GroupNumber: 1, Percent: Ptr(60)}).
+		AC(ACControl{ACNumber: 0, Mode: Ptr(ACModeCool)}).
+		AC(ACControl{ACNumber: 0, Mode: Ptr(ACModeHeat), Setpoint: Ptr(Celsius(21))})
+
+	assert.Equal(t, 2, b.Packets())
+	assert.Equal(t, [][]GroupControl{{
+		{GroupNumber: 1, Power: Ptr(GroupPowerOn), Percent: Ptr(60)},
+		{GroupNumber: 3, Power: Ptr(GroupPowerOff)},
+	}}, b.groups)
+	assert.Equal(t, [][]ACControl{{
+		{ACNumber: 0, Mode: Ptr(ACModeHeat), Setpoint: Ptr(Celsius(21))},
+	}}, b.acs)
+}
+
+func TestBatch_RelativeCommands(t *testing.T) {
+	b := new(Client).Batch().
+		Group(GroupControl{GroupNumber: 1, Value: Ptr(GroupValueIncrease)}).
+		Group(GroupControl{GroupNumber: 1, Power: Ptr(GroupPowerOn)}). // merges
+		Group(GroupControl{GroupNumber: 1, Value: Ptr(GroupValueIncrease)}).
+		Group(GroupControl{GroupNumber: 2, Percent: Ptr(30)})
+
+	// The second increase needs its own packet; group 2 goes with it
+	require.Len(t, b.groups, 2)
+	assert.Equal(t, []GroupControl{
+		{GroupNumber: 1, Power: Ptr(GroupPowerOn), Value: Ptr(GroupValueIncrease)},
+	}, b.groups[0])
+	assert.Equal(t, []GroupControl{
+		{GroupNumber: 1, Value: Ptr(GroupValueIncrease)},
+		{GroupNumber: 2, Percent: Ptr(30)},
+	}, b.groups[1])
+
+	b.AC(ACControl{ACNumber: 0, Power: Ptr(ACPowerToggle)}).
+		AC(ACControl{ACNumber: 0, Power: Ptr(ACPowerToggle)})
+	assert.Equal(t, 4, b.Packets())
+}
+
+func TestBatch_Send(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+	b := client.Batch().
+		Group(GroupControl{GroupNumber: 0, Percent: Ptr(40)}).
+		Group(GroupControl{GroupNumber: 2, Power: Ptr(GroupPowerOff)}).
+		AC(ACControl{ACNumber: 1, FanSpeed: Ptr(FanSpeedLow)})
+
+	groupData, err := MarshalGroupControl(b.groups[0])
+	require.NoError(t, err)
+	acData, err := MarshalACControl(b.acs[0])
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, want := range [][]byte{groupData, acData} {
+			req := readRequest(t, conn)
+			assert.Equal(t, want, req.Data)
+			writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, nil)
+		}
+	}()
+
+	require.NoError(t, b.Send(context.Background()))
+	<-done
+	assert.Equal(t, 0, b.Packets())
+}
+
+func TestCoalescer(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+	errCh := make(chan error, 1)
+	co, err := client.Coalesce(50*time.Millisecond, WithErrorHandler(func(err error) { errCh <- err }))
+	require.NoError(t, err)
+
+	for _, pct := range []int{10, 20, 30, 40} {
+		require.NoError(t, co.Group(GroupControl{GroupNumber: 1, Percent: Ptr(pct)}))
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// One write, with the last value
+	want, err := MarshalGroupControl([]GroupControl{{GroupNumber: 1, Percent: Ptr(40)}})
+	require.NoError(t, err)
+	req := readRequest(t, conn)
+	assert.Equal(t, want, req.Data)
+	writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, nil)
+
+	conn.SetReadDeadline(time.Now().Add(150 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	var ne interface{ Timeout() bool }
+	assert.True(t, errors.As(err, &ne) && ne.Timeout(), "unexpected second write")
+	assert.Empty(t, errCh)
+
+	require.NoError(t, co.Close())
+	assert.ErrorIs(t, co.AC(ACControl{ACNumber: 0, Power: Ptr(ACPowerOff)}), ErrClosed)
+}
+
+func TestCoalescer_MaxDelay(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+	co, err := client.Coalesce(time.Hour, WithMaxDelay(50*time.Millisecond))
+	require.NoError(t, err)
+	t.Cleanup(func() { co.Close() })
+
+	start := time.Now()
+	require.NoError(t, co.AC(ACControl{ACNumber: 0, Power: Ptr(ACPowerOn)}))
+	req := readRequest(t, conn)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, nil)
+}
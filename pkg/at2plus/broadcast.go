@@ -0,0 +1,181 @@
+package at2plus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// Broadcast discovery is not part of the AirTouch 2+ protocol spec. It is
+// the probe AirTouch consoles answer in the field: a UDP datagram with
+// BroadcastRequest sent to port 49004 or 49005, answered to the sender
+// with one line of comma separated fields:
+//
+//	IP,ConsoleID,DeviceType,AirTouchID[,Name]
+//
+// for example "192.168.1.50,554B1234567,AirTouch2+,12345678".
+
+// BroadcastRequest is the payload of a discovery broadcast.
+const BroadcastRequest = "::REQUEST-POLYAIRE-AIRTOUCH-DEVICE-INFO:;"
+
+// DefaultBroadcastPorts are the UDP ports consoles listen on for
+// discovery broadcasts.
+var DefaultBroadcastPorts = []int{49004, 49005}
+
+// UnmarshalBroadcastReply parses a console's answer to a discovery
+// broadcast. The result's Port is not set.
+func UnmarshalBroadcastReply(data []byte) (DiscoveryResult, error) {
+	fields := strings.Split(string(bytes.TrimSpace(data)), ",")
+	if len(fields) < 3 {
+		return DiscoveryResult{}, fmt.Errorf("%w: broadcast reply has %d fields", ErrInvalidLength, len(fields))
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	ip, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return DiscoveryResult{}, fmt.Errorf("%w: broadcast reply IP %q", ErrInvalidHeader, fields[0])
+	}
+	res := DiscoveryResult{
+		IP:         ip.String(),
+		ConsoleID:  fields[1],
+		DeviceType: fields[2],
+	}
+	if len(fields) > 3 {
+		res.AirTouchID = fields[3]
+	}
+	if len(fields) > 4 {
+		res.Name = fields[4]
+	}
+	return res, nil
+}
+
+// MarshalBroadcastReply creates a console's answer to a discovery
+// broadcast, as sent by the device.
+func MarshalBroadcastReply(res DiscoveryResult) ([]byte, error) {
+	fields := []string{res.IP, res.ConsoleID, res.DeviceType, res.AirTouchID}
+	if res.Name != "" {
+		fields = append(fields, res.Name)
+	}
+	for _, f := range fields {
+		if strings.Contains(f, ",") {
+			return nil, fmt.Errorf("broadcast reply field %q contains a comma", f)
+		}
+	}
+	return []byte(strings.Join(fields, ",")), nil
+}
+
+// broadcastDiscover sends discovery broadcasts and reports every console
+// that answers within the broadcast wait, or until ctx is done.
+func broadcastDiscover(ctx context.Context, cfg *discoverConfig, out chan<- DiscoveryResult) error {
+	dests, err := broadcastDests(cfg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	defer conn.Close()
+	listenCtx, cancel := context.WithTimeout(ctx, cfg.broadcastWait)
+	defer cancel()
+	go func() {
+		<-listenCtx.Done()
+		conn.Close()
+	}()
+
+	sent := 0
+	for _, dest := range dests {
+		if _, err := conn.WriteToUDPAddrPort([]byte(BroadcastRequest), dest); err == nil {
+			sent++
+		}
+	}
+	if sent == 0 {
+		return errors.New("no broadcast could be sent")
+	}
+
+	// Handshakes send to out too, so they must end before we return
+	var handshakes sync.WaitGroup
+	defer handshakes.Wait()
+
+	buf := make([]byte, 512)
+	for {
+		n, _, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			// Closed because the wait is over or ctx is done
+			return nil
+		}
+		res, err := UnmarshalBroadcastReply(buf[:n])
+		if err != nil {
+			continue
+		}
+		res.Port = cfg.ports[0]
+
+		if cfg.handshake {
+			// Fill in the system details; a console that answered the
+			// broadcast is reported even if the handshake fails
+			handshakes.Add(1)
+			go func() {
+				defer handshakes.Done()
+				if verified, err := handshake(ctx, res.IP, res.Port, cfg.dialTimeout); err == nil {
+					verified = mergeResult(verified, res)
+					select {
+					case out <- verified:
+					case <-ctx.Done():
+					}
+				}
+			}()
+		}
+
+		select {
+		case out <- res:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// broadcastDests returns the addresses discovery broadcasts are sent to:
+// the configured ones, or the limited and directed broadcast addresses of
+// the local networks on DefaultBroadcastPorts.
+func broadcastDests(cfg *discoverConfig) ([]netip.AddrPort, error) {
+	if len(cfg.broadcastAddrs) > 0 {
+		return cfg.broadcastAddrs, nil
+	}
+
+	nets, err := localNetworks(cfg.interfaces)
+	if err != nil {
+		return nil, fmt.Errorf("get local networks: %w", err)
+	}
+	addrs := []netip.Addr{netip.AddrFrom4([4]byte{255, 255, 255, 255})}
+	for _, p := range nets {
+		addrs = append(addrs, lastAddr(p))
+	}
+
+	var dests []netip.AddrPort
+	for _, a := range addrs {
+		for _, port := range DefaultBroadcastPorts {
+			dests = append(dests, netip.AddrPortFrom(a, uint16(port)))
+		}
+	}
+	return dests, nil
+}
+
+// lastAddr returns the highest address of p, its IPv4 broadcast address.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().As4()
+	hostBits := 32 - p.Bits()
+	for i := 3; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	return netip.AddrFrom4(b)
+}
@@ -0,0 +1,56 @@
+package at2plus
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalBroadcastReply(t *testing.T) {
+	res, err := UnmarshalBroadcastReply([]byte("192.168.1.50,554B1234567,AirTouch2+,12345678\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, DiscoveryResult{
+		IP:         "192.168.1.50",
+		ConsoleID:  "554B1234567",
+		DeviceType: "AirTouch2+",
+		AirTouchID: "12345678",
+	}, res)
+
+	res, err = UnmarshalBroadcastReply([]byte("10.0.0.9,ABC,AirTouch5,42,Home"))
+	require.NoError(t, err)
+	assert.Equal(t, "Home", res.Name)
+
+	_, err = UnmarshalBroadcastReply([]byte("10.0.0.9,ABC"))
+	assert.ErrorIs(t, err, ErrProtocol)
+	_, err = UnmarshalBroadcastReply([]byte("not-an-ip,ABC,AirTouch2+"))
+	assert.ErrorIs(t, err, ErrProtocol)
+}
+
+func TestMarshalBroadcastReply_RoundTrip(t *testing.T) {
+	want := DiscoveryResult{IP: "127.0.0.1", ConsoleID: "C1", DeviceType: "AirTouch2+", AirTouchID: "7", Name: "Flat"}
+	data, err := MarshalBroadcastReply(want)
+	require.NoError(t, err)
+	got, err := UnmarshalBroadcastReply(data)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = MarshalBroadcastReply(DiscoveryResult{IP: "127.0.0.1", Name: "a,b"})
+	assert.Error(t, err)
+}
+
+func TestMergeResult(t *testing.T) {
+	scanned := DiscoveryResult{IP: "10.0.0.9", Port: 9200, Verified: true, GroupCount: 4, ACCount: 1}
+	announced := DiscoveryResult{IP: "10.0.0.9", Port: 9200, ConsoleID: "C1", DeviceType: "AirTouch2+"}
+
+	want := DiscoveryResult{IP: "10.0.0.9", Port: 9200, ConsoleID: "C1", DeviceType: "AirTouch2+", Verified: true, GroupCount: 4, ACCount: 1}
+	assert.Equal(t, want, mergeResult(scanned, announced))
+	assert.Equal(t, want, mergeResult(announced, scanned))
+}
+
+func TestLastAddr(t *testing.T) {
+	assert.Equal(t, netip.MustParseAddr("192.168.1.255"), lastAddr(netip.MustParsePrefix("192.168.1.0/24")))
+	assert.Equal(t, netip.MustParseAddr("10.1.3.255"), lastAddr(netip.MustParsePrefix("10.1.0.0/22")))
+	assert.Equal(t, netip.MustParseAddr("172.16.255.255"), lastAddr(netip.MustParsePrefix("172.16.4.0/16")))
+}
@@ -2,8 +2,8 @@ package at2plus
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net"
 	"sync"
@@ -13,21 +13,67 @@ import (
 // Client represents a connection to an AirTouch 2+ device.
 type Client struct {
 	conn           net.Conn
-	addr           string
+	addr           string // guarded by mu
 	port           int
+	device         string // MAC address or device ID the client was given
+	discoverOpts   []DiscoverOption
+	connectTimeout time.Duration
 	requestTimeout time.Duration
+	reconnect      bool
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	pendingPolicy  PendingPolicy
+	stateHandler   func(ConnState, error)
+	validation     ValidationMode
+	confirmTimeout time.Duration
 	logger         *slog.Logger
 	mu             sync.Mutex
-	pending        map[uint8]chan *Packet
+	state          ConnState
+	pending        map[uint8]*pendingRequest
 	pendingMu      sync.Mutex
-	nextMsgID      uint8
+	msgIDs         msgIDAllocator // guarded by pendingMu
 	closeCh        chan struct{}
+	closeErr       error
 	isClosed       bool
+	subscribers    map[*Subscription]struct{}
+	subMu          sync.Mutex
+	abilities      map[uint8]ACAbility
+	abilityMu      sync.Mutex
+	groupNames     []unitName // guarded by nameMu; nil until fetched
+	acNames        []unitName // guarded by nameMu; nil until fetched
+	nameMu         sync.Mutex
+	queue          [2][]*pendingRequest // indexed by priority
+	queueMu        sync.Mutex
+	queueCh        chan struct{}
+	replayCh       chan struct{}
+	slots          chan struct{}
+	stats          clientStats
+	framer         *Framer // used by the run goroutine only
+}
+
+// pendingRequest is a request waiting to be sent or for its response.
+// The encoded frame is kept so it can be replayed after a reconnect.
+type pendingRequest struct {
+	msgID    uint8
+	priority priority
+	frame    []byte
+	respCh   chan response
+	done     chan struct{} // closed when the caller stops waiting
+}
+
+// response completes a pending request with either a packet or an error.
+type response struct {
+	packet *Packet
+	err    error
 }
 
 // NewClient creates a new client and connects to the device.
 // The context is used for the connection timeout.
 // Options can be provided to configure the client behavior.
+//
+// ip may also be the MAC address or device ID of a device (see
+// DiscoveryResult.DeviceID), which is then looked up on the network with
+// ResolveDevice first. The lookup shares the connection timeout.
 func NewClient(ctx context.Context, ip string, opts ...ClientOption) (*Client, error) {
 	cfg := defaultConfig()
 	for _, opt := range opts {
@@ -43,6 +89,18 @@ func NewClient(ctx context.Context, ip string, opts ...ClientOption) (*Client, e
 		defer cancel()
 	}
 
+	device := ""
+	if isDeviceRef(ip) {
+		res, err := ResolveDevice(ctx, ip, deviceDiscoverOptions(cfg.port, cfg.discoverOpts)...)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", ip, err)
+		}
+		device, ip = ip, res.IP
+		if cfg.logger != nil {
+			cfg.logger.Debug("resolved device", "device", device, "ip", ip)
+		}
+	}
+
 	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", cfg.port))
 	var d net.Dialer
 	conn, err := d.DialContext(ctx, "tcp", addr)
@@ -54,91 +112,120 @@ func NewClient(ctx context.Context, ip string, opts ...ClientOption) (*Client, e
 		conn:           conn,
 		addr:           addr,
 		port:           cfg.port,
+		device:         device,
+		discoverOpts:   cfg.discoverOpts,
+		connectTimeout: cfg.connectTimeout,
 		requestTimeout: cfg.requestTimeout,
+		reconnect:      cfg.reconnect,
+		minBackoff:     cfg.minBackoff,
+		maxBackoff:     cfg.maxBackoff,
+		pendingPolicy:  cfg.pendingPolicy,
+		stateHandler:   cfg.stateHandler,
+		validation:     cfg.validation,
+		confirmTimeout: cfg.confirmTimeout,
 		logger:         cfg.logger,
-		pending:        make(map[uint8]chan *Packet),
+		pending:        make(map[uint8]*pendingRequest),
 		closeCh:        make(chan struct{}),
+		subscribers:    make(map[*Subscription]struct{}),
+		abilities:      make(map[uint8]ACAbility),
+		framer:         NewFramer(conn),
+		queueCh:        make(chan struct{}, 1),
+		replayCh:       make(chan struct{}, 1),
+		slots:          make(chan struct{}, cfg.maxInFlight),
 	}
 
 	if c.logger != nil {
 		c.logger.Debug("connected to device", "addr", addr)
 	}
+	c.setState(StateConnected, nil)
 
-	go c.readLoop()
+	go c.run(conn)
+	go c.writeLoop()
 
 	return c, nil
 }
 
-// Close closes the connection
+// Close closes the connection and ends all subscriptions.
+// Requests still waiting for a response fail with ErrClosed.
 func (c *Client) Close() error {
+	return c.shutdown(nil)
+}
+
+// shutdown closes the client for good. cause is the connection error that
+// ended a non-reconnecting client, or nil when Close was called.
+func (c *Client) shutdown(cause error) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	if c.isClosed {
+		c.mu.Unlock()
 		return nil
 	}
 	c.isClosed = true
+	c.closeErr = ErrClosed
+	if cause != nil {
+		c.closeErr = fmt.Errorf("%w: %w: %w", ErrClosed, ErrConnectionLost, cause)
+	}
 	close(c.closeCh)
 	if c.logger != nil {
-		c.logger.Debug("connection closed", "addr", c.addr)
+		c.logger.Debug("connection closed", "addr", c.addr, "error", cause)
+	}
+	var err error
+	if c.conn != nil {
+		err = c.conn.Close()
 	}
-	return c.conn.Close()
+	c.mu.Unlock()
+
+	c.failPending(c.closeErr)
+	c.closeSubscribers()
+	c.setState(StateClosed, cause)
+	return err
 }
 
-func (c *Client) readLoop() {
+// closedErr returns the error requests fail with once the client is closed.
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeErr
+}
+
+// run owns the connection: it reads until the connection fails and then
+// either gives up or, in reconnecting mode, dials again.
+func (c *Client) run(conn net.Conn) {
 	for {
+		err := c.readLoop(conn)
+
 		select {
 		case <-c.closeCh:
 			return
 		default:
-			// Read packet header: Header(2)+Addr(2)+ID(1)+Type(1)+Len(2)
-			headerBuf := make([]byte, 8)
-			_, err := io.ReadFull(c.conn, headerBuf)
-			if err != nil {
-				if c.logger != nil {
-					c.logger.Error("failed to read header", "error", err)
-				}
-				c.Close()
-				return
-			}
-
-			// Check header magic bytes
-			if headerBuf[0] != 0x55 || headerBuf[1] != 0x55 {
-				if c.logger != nil {
-					c.logger.Warn("invalid header, out of sync", "header", headerBuf[:2])
-				}
-				continue
-			}
-
-			dataLen := int(headerBuf[6])<<8 | int(headerBuf[7])
+		}
 
-			// Validate data length to prevent excessive memory allocation
-			if dataLen > MaxDataLen {
-				if c.logger != nil {
-					c.logger.Warn("packet exceeds max length", "dataLen", dataLen, "max", MaxDataLen)
-				}
-				continue
-			}
+		if !c.reconnect {
+			c.shutdown(err)
+			return
+		}
 
-			// Read Data + CRC (2 bytes)
-			toRead := dataLen + 2
-			dataBuf := make([]byte, toRead)
-			_, err = io.ReadFull(c.conn, dataBuf)
-			if err != nil {
-				if c.logger != nil {
-					c.logger.Error("failed to read data", "error", err)
-				}
-				c.Close()
-				return
-			}
+		c.disconnected(conn, err)
+		conn = c.redial()
+		if conn == nil {
+			return
+		}
+	}
+}
 
-			// Combine and decode
-			fullPacket := append(headerBuf, dataBuf...)
-			packet, err := Decode(fullPacket)
+// readLoop reads packets from conn until a read fails or the client is closed.
+func (c *Client) readLoop(conn net.Conn) error {
+	c.framer.Reset(conn)
+	for {
+		select {
+		case <-c.closeCh:
+			return nil
+		default:
+			packet, err := c.framer.Next()
 			if err != nil {
 				if c.logger != nil {
-					c.logger.Warn("failed to decode packet", "error", err)
+					c.logger.Error("failed to read packet", "error", err)
 				}
-				continue
+				return err
 			}
 
 			if c.logger != nil {
@@ -147,22 +234,60 @@ func (c *Client) readLoop() {
 
 			// Dispatch to waiting request
 			c.pendingMu.Lock()
-			ch, ok := c.pending[packet.MsgID]
+			req, ok := c.pending[packet.MsgID]
+			late := false
 			if ok {
-				ch <- packet
+				req.respCh <- response{packet: packet}
 				delete(c.pending, packet.MsgID)
+			} else {
+				late = c.msgIDs.late(packet.MsgID, time.Now())
 			}
 			c.pendingMu.Unlock()
+
+			switch {
+			case ok:
+			case late:
+				c.stats.lateResponses.Add(1)
+				if c.logger != nil {
+					c.logger.Warn("discarding late response", "msgID", packet.MsgID)
+				}
+			default:
+				// Anything nobody asked for is an unsolicited update
+				c.publish(packet)
+			}
 		}
 	}
 }
 
+// write sends a frame on the current connection.
+// It returns ErrConnectionLost if the client is between connections.
+func (c *Client) write(frame []byte) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrConnectionLost
+	}
+	_, err := conn.Write(frame)
+	return err
+}
+
 func (c *Client) sendRequest(ctx context.Context, msgType uint8, data []byte) (*Packet, error) {
 	c.mu.Lock()
-	msgID := c.nextMsgID
-	c.nextMsgID++
+	if c.isClosed {
+		err := c.closeErr
+		c.mu.Unlock()
+		return nil, err
+	}
 	c.mu.Unlock()
 
+	c.pendingMu.Lock()
+	msgID, ok := c.msgIDs.alloc(time.Now())
+	c.pendingMu.Unlock()
+	if !ok {
+		return nil, errors.New("no free message ID: too many outstanding requests")
+	}
+
 	// Determine Address based on MsgType
 	addr := AddressSendStandard
 	if msgType == MsgTypeExtended {
@@ -170,29 +295,15 @@ func (c *Client) sendRequest(ctx context.Context, msgType uint8, data []byte) (*
 	}
 
 	p := NewPacket(uint16(addr), msgID, msgType, data)
-	encoded := p.Encode()
-
-	// Register channel
-	respCh := make(chan *Packet, 1)
-	c.pendingMu.Lock()
-	c.pending[msgID] = respCh
-	c.pendingMu.Unlock()
-
-	// Send
-	_, err := c.conn.Write(encoded)
-	if err != nil {
-		c.pendingMu.Lock()
-		delete(c.pending, msgID)
-		c.pendingMu.Unlock()
-		if c.logger != nil {
-			c.logger.Error("failed to send request", "msgID", msgID, "error", err)
-		}
-		return nil, fmt.Errorf("write request (msgID %d): %w", msgID, err)
-	}
-
-	if c.logger != nil {
-		c.logger.Debug("request sent", "msgID", msgID, "msgType", msgType, "dataLen", len(data))
+	req := &pendingRequest{
+		msgID:    msgID,
+		priority: priorityOf(msgType, data),
+		frame:    p.Encode(),
+		respCh:   make(chan response, 1),
+		done:     make(chan struct{}),
 	}
+	c.enqueue(req)
+	defer c.finish(req)
 
 	// Apply request timeout if context has no deadline
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
@@ -203,17 +314,22 @@ func (c *Client) sendRequest(ctx context.Context, msgType uint8, data []byte) (*
 
 	// Wait for response
 	select {
-	case resp := <-respCh:
+	case resp := <-req.respCh:
+		if resp.err != nil {
+			return nil, fmt.Errorf("request (msgID %d): %w", msgID, resp.err)
+		}
 		if c.logger != nil {
 			c.logger.Debug("response received", "msgID", msgID)
 		}
-		return resp, nil
+		return resp.packet, nil
+	case <-c.closeCh:
+		return nil, fmt.Errorf("request (msgID %d): %w", msgID, c.closedErr())
 	case <-ctx.Done():
-		c.pendingMu.Lock()
-		delete(c.pending, msgID)
-		c.pendingMu.Unlock()
-		if c.logger != nil {
-			c.logger.Warn("request timeout", "msgID", msgID)
+		if ctx.Err() == context.DeadlineExceeded {
+			if c.logger != nil {
+				c.logger.Warn("request timeout", "msgID", msgID)
+			}
+			return nil, fmt.Errorf("request (msgID %d): %w: %w", msgID, ErrTimeout, ctx.Err())
 		}
 		return nil, fmt.Errorf("request canceled: %w", ctx.Err())
 	}
@@ -252,30 +368,68 @@ func (c *Client) GetACStatus(ctx context.Context) ([]ACStatus, error) {
 }
 
 // SetGroupControl sends a control command to groups.
+// If the client was created with WithConfirm, it then waits until the
+// device reports the new settings and returns a *NotAppliedError if it
+// does not.
 func (c *Client) SetGroupControl(ctx context.Context, groups []GroupControl) error {
 	data, err := MarshalGroupControl(groups)
 	if err != nil {
 		return fmt.Errorf("set group control: %w", err)
 	}
 
+	sub, err := c.confirmSubscribe(ctx)
+	if err != nil {
+		return fmt.Errorf("set group control: %w", err)
+	}
+	if sub != nil {
+		defer sub.Close()
+	}
+
 	_, err = c.sendRequest(ctx, MsgTypeControlStatus, data)
 	if err != nil {
 		return fmt.Errorf("set group control: %w", err)
 	}
+	if sub != nil {
+		if err := c.confirmGroups(ctx, sub, groups); err != nil {
+			return fmt.Errorf("set group control: %w", err)
+		}
+	}
 	return nil
 }
 
 // SetACControl sends a control command to ACs.
+// If the client was created with WithValidation, the commands are first
+// checked against the abilities of their ACs and a *ValidationError is
+// returned for unsupported settings. With WithConfirm, it then waits
+// until the device reports the new settings, as SetGroupControl does.
 func (c *Client) SetACControl(ctx context.Context, acs []ACControl) error {
+	acs, err := c.ValidateACControl(ctx, acs, c.validation)
+	if err != nil {
+		return fmt.Errorf("set AC control: %w", err)
+	}
+
 	data, err := MarshalACControl(acs)
 	if err != nil {
 		return fmt.Errorf("set AC control: %w", err)
 	}
 
+	sub, err := c.confirmSubscribe(ctx)
+	if err != nil {
+		return fmt.Errorf("set AC control: %w", err)
+	}
+	if sub != nil {
+		defer sub.Close()
+	}
+
 	_, err = c.sendRequest(ctx, MsgTypeControlStatus, data)
 	if err != nil {
 		return fmt.Errorf("set AC control: %w", err)
 	}
+	if sub != nil {
+		if err := c.confirmACs(ctx, sub, acs); err != nil {
+			return fmt.Errorf("set AC control: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -310,3 +464,19 @@ func (c *Client) GetGroupNames(ctx context.Context) ([]GroupName, error) {
 	}
 	return names, nil
 }
+
+// GetACError requests the error information of a specific AC unit.
+func (c *Client) GetACError(ctx context.Context, acNum uint8) (ACError, error) {
+	payload := []byte{0xFF, ExtMsgTypeACError, acNum}
+
+	resp, err := c.sendRequest(ctx, MsgTypeExtended, payload)
+	if err != nil {
+		return ACError{}, fmt.Errorf("get AC error (AC %d): %w", acNum, err)
+	}
+
+	acErr, err := UnmarshalACError(resp.Data)
+	if err != nil {
+		return ACError{}, fmt.Errorf("get AC error (AC %d): %w", acNum, err)
+	}
+	return acErr, nil
+}
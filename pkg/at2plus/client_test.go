@@ -0,0 +1,192 @@
+package at2plus
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDevice is a bare TCP listener standing in for an AirTouch 2+.
+// Tests drive the accepted connection directly to control exactly what
+// bytes the client sees.
+type fakeDevice struct {
+	ln    net.Listener
+	conns chan net.Conn
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	d := &fakeDevice{ln: ln, conns: make(chan net.Conn, 4)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			d.conns <- conn
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return d
+}
+
+// dial connects a new Client to the fake device and returns both ends.
+func (d *fakeDevice) dial(t *testing.T, opts ...ClientOption) (*Client, net.Conn) {
+	t.Helper()
+	port := d.ln.Addr().(*net.TCPAddr).Port
+	opts = append([]ClientOption{WithPort(port), WithRequestTimeout(time.Second)}, opts...)
+	client, err := NewClient(context.Background(), "127.0.0.1", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client, d.accept(t)
+}
+
+// accept waits for the next connection made to the fake device.
+func (d *fakeDevice) accept(t *testing.T) net.Conn {
+	t.Helper()
+	select {
+	case conn := <-d.conns:
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("fake device did not accept connection")
+		return nil
+	}
+}
+
+// readRequest reads one request packet from the device side of the connection.
+func readRequest(t *testing.T, conn net.Conn) *Packet {
+	t.Helper()
+	header := make([]byte, 8)
+	_, err := io.ReadFull(conn, header)
+	require.NoError(t, err)
+	rest := make([]byte, int(header[6])<<8|int(header[7])+2)
+	_, err = io.ReadFull(conn, rest)
+	require.NoError(t, err)
+	p, err := Decode(append(header, rest...))
+	require.NoError(t, err)
+	return p
+}
+
+// writeResponse sends a device-to-client packet with the given message ID.
+func writeResponse(t *testing.T, conn net.Conn, msgID uint8, msgType uint8, data []byte) {
+	t.Helper()
+	addr := uint16(AddressRecvStandard)
+	if msgType == MsgTypeExtended {
+		addr = AddressRecvExtended
+	}
+	_, err := conn.Write(NewPacket(addr, msgID, msgType, data).Encode())
+	require.NoError(t, err)
+}
+
+var specGroupStatusData, _ = hex.DecodeString("210000000002000800000000000080004132000000000200")
+
+func TestClient_GetGroupStatus(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	go func() {
+		req := readRequest(t, conn)
+		writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	}()
+
+	groups, err := client.GetGroupStatus(context.Background())
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, 50, groups[1].Percent)
+}
+
+func TestClient_RequestTimeout(t *testing.T) {
+	client, _ := newFakeDevice(t).dial(t, WithRequestTimeout(50*time.Millisecond))
+
+	_, err := client.GetACStatus(context.Background())
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_ConnectionLostFailsPending(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t, WithRequestTimeout(5*time.Second))
+
+	go func() {
+		readRequest(t, conn)
+		conn.Close()
+	}()
+
+	start := time.Now()
+	_, err := client.GetGroupStatus(context.Background())
+	assert.ErrorIs(t, err, ErrConnectionLost)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Less(t, time.Since(start), time.Second, "must not wait for the request timeout")
+
+	_, err = client.GetGroupStatus(context.Background())
+	assert.ErrorIs(t, err, ErrConnectionLost)
+}
+
+func TestClient_CloseFailsPending(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t, WithRequestTimeout(5*time.Second))
+
+	go func() {
+		readRequest(t, conn)
+		client.Close()
+	}()
+
+	_, err := client.GetGroupStatus(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.NotErrorIs(t, err, ErrConnectionLost)
+
+	_, err = client.GetGroupStatus(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestClient_ProtocolError(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	go func() {
+		req := readRequest(t, conn)
+		writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, []byte{0x21, 0x00})
+	}()
+
+	_, err := client.GetGroupStatus(context.Background())
+	assert.ErrorIs(t, err, ErrProtocol)
+}
+
+func TestClient_GetACError(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	go func() {
+		req := readRequest(t, conn)
+		assert.Equal(t, []byte{0xFF, ExtMsgTypeACError, 0x02}, req.Data)
+		data, _ := hex.DecodeString("ff10020845523a2046464645")
+		writeResponse(t, conn, req.MsgID, MsgTypeExtended, data)
+	}()
+
+	acErr, err := client.GetACError(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(2), acErr.ACNumber)
+	assert.Equal(t, "ER: FFFE", acErr.Message)
+}
+
+func TestClient_ResyncAfterGarbage(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	go func() {
+		req := readRequest(t, conn)
+		_, err := conn.Write([]byte{0x55, 0x00, 0x13})
+		require.NoError(t, err)
+		writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	}()
+
+	groups, err := client.GetGroupStatus(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, uint64(3), client.Stats().DroppedBytes)
+}
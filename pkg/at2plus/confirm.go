@@ -0,0 +1,245 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// confirmPollInterval is how often the status is queried while waiting
+// for a control command to be confirmed. Pushed status updates are
+// checked as they arrive.
+var confirmPollInterval = 500 * time.Millisecond
+
+// Divergence is a setting of a control command that the device does not
+// report as applied.
+type Divergence struct {
+	// Kind is "group" or "AC".
+	Kind string
+	// Number is the group or AC number.
+	Number uint8
+	// Field is the GroupControl or ACControl field name, e.g. "Percent".
+	Field string
+	// Want is the requested value.
+	Want string
+	// Got is the value the device reports.
+	Got string
+}
+
+// Error implements the error interface.
+func (d Divergence) Error() string {
+	return fmt.Sprintf("%s %d %s: requested %s, device reports %s", d.Kind, d.Number, fieldDisplayName(d.Field), d.Want, d.Got)
+}
+
+// NotAppliedError is returned in confirm mode when the device does not
+// report the requested settings before the confirm timeout.
+type NotAppliedError struct {
+	Divergences []Divergence
+}
+
+// Error implements the error interface.
+func (e *NotAppliedError) Error() string {
+	msgs := make([]string, len(e.Divergences))
+	for i, d := range e.Divergences {
+		msgs[i] = d.Error()
+	}
+	return "not applied: " + strings.Join(msgs, "; ")
+}
+
+// groupPowerApplied maps absolute group power commands to the state they
+// lead to. GroupPowerNext depends on the state before and is not checked.
+var groupPowerApplied = map[GroupPowerCommand]GroupPowerState{
+	GroupPowerOff:   GroupPowerStateOff,
+	GroupPowerOn:    GroupPowerStateOn,
+	GroupPowerTurbo: GroupPowerStateTurbo,
+}
+
+// groupDivergences compares group control commands with reported status.
+// Relative changes (GroupPowerNext, GroupValueIncrease/Decrease) are not
+// checked.
+func groupDivergences(groups []GroupControl, status []GroupStatus) []Divergence {
+	byNum := make(map[uint8]GroupStatus, len(status))
+	for _, st := range status {
+		byNum[st.GroupNumber] = st
+	}
+
+	var divs []Divergence
+	for _, g := range groups {
+		diverged := func(field, want, got string) {
+			divs = append(divs, Divergence{Kind: "group", Number: g.GroupNumber, Field: field, Want: want, Got: got})
+		}
+		st, ok := byNum[g.GroupNumber]
+		if !ok {
+			diverged("GroupNumber", strconv.Itoa(int(g.GroupNumber)), "no such group")
+			continue
+		}
+		if g.Power != nil {
+			if want, ok := groupPowerApplied[*g.Power]; ok && st.Power != want {
+				diverged("Power", want.String(), st.Power.String())
+			}
+		}
+		if g.Percent != nil && (g.Value == nil || *g.Value == GroupValueSet) && st.Percent != *g.Percent {
+			diverged("Percent", strconv.Itoa(*g.Percent), strconv.Itoa(st.Percent))
+		}
+	}
+	return divs
+}
+
+// acPowerApplied reports whether an AC in state s has applied power
+// command p. ACPowerToggle depends on the state before and always passes.
+func acPowerApplied(p ACPowerCommand, s ACPowerState) bool {
+	switch p {
+	case ACPowerOff:
+		return s == ACPowerStateOff
+	case ACPowerOn:
+		return s == ACPowerStateOn
+	case ACPowerAway:
+		return s == ACPowerStateAwayOn || s == ACPowerStateAwayOff
+	case ACPowerSleep:
+		return s == ACPowerStateSleep
+	default:
+		return true
+	}
+}
+
+// acDivergences compares AC control commands with reported status. An AC
+// set to ACModeAuto may report ACModeAutoHeat or ACModeAutoCool.
+func acDivergences(acs []ACControl, status []ACStatus) []Divergence {
+	byNum := make(map[uint8]ACStatus, len(status))
+	for _, st := range status {
+		byNum[st.ACNumber] = st
+	}
+
+	var divs []Divergence
+	for _, ac := range acs {
+		diverged := func(field, want, got string) {
+			divs = append(divs, Divergence{Kind: "AC", Number: ac.ACNumber, Field: field, Want: want, Got: got})
+		}
+		st, ok := byNum[ac.ACNumber]
+		if !ok {
+			diverged("ACNumber", strconv.Itoa(int(ac.ACNumber)), "no such AC")
+			continue
+		}
+		if ac.Power != nil && !acPowerApplied(*ac.Power, st.Power) {
+			diverged("Power", ac.Power.String(), st.Power.String())
+		}
+		if ac.Mode != nil && st.Mode != *ac.Mode &&
+			!(*ac.Mode == ACModeAuto && (st.Mode == ACModeAutoHeat || st.Mode == ACModeAutoCool)) {
+			diverged("Mode", ac.Mode.String(), st.Mode.String())
+		}
+		if ac.FanSpeed != nil && st.FanSpeed != *ac.FanSpeed {
+			diverged("FanSpeed", ac.FanSpeed.String(), st.FanSpeed.String())
+		}
+		if ac.Setpoint != nil && st.Setpoint != *ac.Setpoint {
+			diverged("Setpoint", ac.Setpoint.String(), st.Setpoint.String())
+		}
+	}
+	return divs
+}
+
+// confirmSubscribe subscribes to status updates ahead of sending a
+// control command in confirm mode, so none is missed. It returns nil if
+// confirm mode is off.
+func (c *Client) confirmSubscribe(ctx context.Context) (*Subscription, error) {
+	if c.confirmTimeout == 0 {
+		return nil, nil
+	}
+	return c.Subscribe(ctx, WithBufferSize(4))
+}
+
+// confirmGroups waits until the device reports groups as applied.
+func (c *Client) confirmGroups(ctx context.Context, sub *Subscription, groups []GroupControl) error {
+	return c.confirmApplied(ctx, sub,
+		func(ctx context.Context) ([]Divergence, error) {
+			status, err := c.GetGroupStatus(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return groupDivergences(groups, status), nil
+		},
+		func(u Update) ([]Divergence, bool) {
+			if u.Groups == nil {
+				return nil, false
+			}
+			return groupDivergences(groups, u.Groups), true
+		},
+	)
+}
+
+// confirmACs waits until the device reports acs as applied.
+func (c *Client) confirmACs(ctx context.Context, sub *Subscription, acs []ACControl) error {
+	return c.confirmApplied(ctx, sub,
+		func(ctx context.Context) ([]Divergence, error) {
+			status, err := c.GetACStatus(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return acDivergences(acs, status), nil
+		},
+		func(u Update) ([]Divergence, bool) {
+			if u.ACs == nil {
+				return nil, false
+			}
+			return acDivergences(acs, u.ACs), true
+		},
+	)
+}
+
+// confirmApplied checks the status the device reports, polled with poll
+// and pushed to sub, until it shows no divergence or the confirm timeout
+// passes. Failed polls are retried; if no status was seen at all the last
+// poll error is returned.
+func (c *Client) confirmApplied(ctx context.Context, sub *Subscription, poll func(context.Context) ([]Divergence, error), pushed func(Update) ([]Divergence, bool)) error {
+	confirmCtx, cancel := context.WithTimeout(ctx, c.confirmTimeout)
+	defer cancel()
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+
+	var (
+		last    []Divergence
+		seen    bool
+		pollErr error
+	)
+	for {
+		divs, err := poll(confirmCtx)
+		switch {
+		case errors.Is(err, ErrClosed):
+			return err
+		case err != nil:
+			pollErr = err
+		case len(divs) == 0:
+			return nil
+		default:
+			last, seen = divs, true
+		}
+
+	wait:
+		for {
+			select {
+			case u, ok := <-sub.Updates():
+				if !ok {
+					return fmt.Errorf("confirm: %w", ErrClosed)
+				}
+				if divs, ok := pushed(u); ok {
+					if len(divs) == 0 {
+						return nil
+					}
+					last, seen = divs, true
+				}
+			case <-ticker.C:
+				break wait
+			case <-confirmCtx.Done():
+				switch {
+				case ctx.Err() != nil:
+					return fmt.Errorf("confirm: %w", ctx.Err())
+				case !seen && pollErr != nil:
+					return fmt.Errorf("confirm: %w", pollErr)
+				}
+				return &NotAppliedError{Divergences: last}
+			}
+		}
+	}
+}
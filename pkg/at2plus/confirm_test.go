@@ -0,0 +1,97 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupDivergences(t *testing.T) {
+	status := []GroupStatus{
+		{GroupNumber: 0, Power: GroupPowerStateOn, Percent: 40},
+		{GroupNumber: 1, Power: GroupPowerStateOff, Percent: 0},
+	}
+
+	assert.Empty(t, groupDivergences([]GroupControl{
+		{GroupNumber: 0, Power: Ptr(GroupPowerOn), Percent: Ptr(40)},
+		{GroupNumber: 1, Power: Ptr(GroupPowerNext), Value: Ptr(GroupValueIncrease), Percent: Ptr(90)},
+	}, status))
+
+	divs := groupDivergences([]GroupControl{
+		{GroupNumber: 0, Power: Ptr(GroupPowerTurbo), Percent: Ptr(60)},
+		{GroupNumber: 5, Power: Ptr(GroupPowerOff)},
+	}, status)
+	assert.Equal(t, []Divergence{
+		{Kind: "group", Number: 0, Field: "Power", Want: "turbo", Got: "on"},
+		{Kind: "group", Number: 0, Field: "Percent", Want: "60", Got: "40"},
+		{Kind: "group", Number: 5, Field: "GroupNumber", Want: "5", Got: "no such group"},
+	}, divs)
+}
+
+func TestACDivergences(t *testing.T) {
+	status := []ACStatus{
+		{ACNumber: 0, Power: ACPowerStateAwayOff, Mode: ACModeAutoCool, FanSpeed: FanSpeedLow, Setpoint: Celsius(22)},
+	}
+
+	assert.Empty(t, acDivergences([]ACControl{
+		{ACNumber: 0, Power: Ptr(ACPowerAway), Mode: Ptr(ACModeAuto), FanSpeed: Ptr(FanSpeedLow), Setpoint: Ptr(Celsius(22))},
+		{ACNumber: 0, Power: Ptr(ACPowerToggle)},
+	}, status))
+
+	divs := acDivergences([]ACControl{
+		{ACNumber: 0, Power: Ptr(ACPowerOff), Mode: Ptr(ACModeHeat), Setpoint: Ptr(Celsius(24))},
+	}, status)
+	assert.Equal(t, []Divergence{
+		{Kind: "AC", Number: 0, Field: "Power", Want: "off", Got: "away-off"},
+		{Kind: "AC", Number: 0, Field: "Mode", Want: "heat", Got: "auto-cool"},
+		{Kind: "AC", Number: 0, Field: "Setpoint", Want: "24.0°C", Got: "22.0°C"},
+	}, divs)
+
+	divs = acDivergences([]ACControl{{ACNumber: 0, FanSpeed: Ptr(FanSpeedHigh)}}, status)
+	require.Len(t, divs, 1)
+	assert.Equal(t, "AC 0 fan speed: requested high, device reports low", divs[0].Error())
+}
+
+// serveStatus answers every request on conn: control commands with an
+// empty acknowledgement, status queries with status.
+func serveStatus(conn net.Conn, status []byte) {
+	framer := NewFramer(conn)
+	for {
+		req, err := framer.Next()
+		if err != nil {
+			return
+		}
+		var data []byte
+		if req.Data[0] == SubMsgTypeGroupStatus {
+			data = status
+		}
+		conn.Write(NewPacket(AddressRecvStandard, req.MsgID, MsgTypeControlStatus, data).Encode())
+	}
+}
+
+func TestClient_Confirm(t *testing.T) {
+	defer func(d time.Duration) { confirmPollInterval = d }(confirmPollInterval)
+	confirmPollInterval = 20 * time.Millisecond
+
+	client, conn := newFakeDevice(t).dial(t, WithConfirm(200*time.Millisecond))
+	status, err := MarshalGroupStatus([]GroupStatus{{GroupNumber: 0, Power: GroupPowerStateOn, Percent: 40}})
+	require.NoError(t, err)
+	go serveStatus(conn, status)
+
+	ctx := context.Background()
+	require.NoError(t, client.SetGroupControl(ctx, []GroupControl{{GroupNumber: 0, Percent: Ptr(40)}}))
+
+	// The device acknowledges but keeps reporting 40%
+	start := time.Now()
+	err = client.SetGroupControl(ctx, []GroupControl{{GroupNumber: 0, Power: Ptr(GroupPowerOff), Percent: Ptr(60)}})
+	var nae *NotAppliedError
+	require.True(t, errors.As(err, &nae), "got %v", err)
+	assert.Len(t, nae.Divergences, 2)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Contains(t, err.Error(), "group 0 power: requested off, device reports on")
+}
@@ -0,0 +1,114 @@
+package at2plus
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// specPackets are all example packets in the protocol spec that include a
+// CRC, exactly as printed.
+var specPackets = []struct {
+	name string
+	hex  string
+	// badLength marks examples whose printed length field does not match
+	// their data. Their CRC is still correct for the bytes as printed.
+	badLength bool
+}{
+	{name: "turn off group 2", hex: "5555 80B0 01 C0 000C 200000000001 0004 01020000 64FD"},
+	{name: "groups to 10%", hex: "5555 80B0 01 C0 0010 200000000002 0004 00800A00 01800A00 2BD2"},
+	{name: "group status request", hex: "5555 80B0 01 C0 0008 2100000000000000 A431"},
+	{name: "group status response", hex: "5555 B080 01 C0 0018 210000000002 0008 0000000000008000 4132000000000200 832F"},
+	{name: "turn off AC 2", hex: "5555 80B0 01 C0 000C 220000000001 0004 21FF00FF D3DE"},
+	{name: "AC cool and 26 degrees", hex: "5555 80B0 01 C0 0010 220000000002 0004 004F00FF 01FF40A0 387E"},
+	{name: "AC status request", hex: "5555 80B0 01 C0 0008 2300000000000000 7DB0"},
+	{name: "AC status response", hex: "5555 B080 01 C0 001C 230000000002 000A 101278C002DA00008000 014264C002E400008000 D397"},
+	{name: "AC error request", hex: "5555 90B0 01 1F 0003 FF1000 9982"},
+	{name: "AC error response", hex: "5555 B090 01 1F 001A FF100008 45523A2046464645 60D3", badLength: true},
+	{name: "group name request", hex: "5555 90B0 01 1F 0003 FF1200 F983"},
+	{name: "group name response", hex: "5555 B090 01 1F 000B FF12 00 47726F7570310000 FD18"},
+	{name: "all group names request", hex: "5555 90B0 01 1F 0002 FF12 820C"},
+	{name: "all group names response", hex: "5555 B090 01 1F 000B FF12 00 4C6976696E670000 01 4B69746368656E00 02 426564726F6F6D00 3993", badLength: true},
+}
+
+func TestChecksum_AllSpecExamples(t *testing.T) {
+	for _, tt := range specPackets {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := hex.DecodeString(strings.ReplaceAll(tt.hex, " ", ""))
+			require.NoError(t, err)
+
+			body, crc := raw[2:len(raw)-2], raw[len(raw)-2:]
+			assert.Equal(t, binary.BigEndian.Uint16(crc), Checksum(body), "CRC is stored high byte first")
+
+			if !tt.badLength {
+				_, err := Decode(raw)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCRC16_Streaming(t *testing.T) {
+	raw, _ := hex.DecodeString("80B001C0000C200000000001000401020000")
+
+	h := NewCRC16()
+	for _, part := range [][]byte{raw[:4], raw[4:5], raw[5:]} {
+		n, err := h.Write(part)
+		require.NoError(t, err)
+		assert.Equal(t, len(part), n)
+	}
+	assert.Equal(t, uint16(0x64FD), h.Sum16())
+	assert.Equal(t, []byte{0xAA, 0x64, 0xFD}, h.Sum([]byte{0xAA}))
+	assert.Equal(t, 2, h.Size())
+
+	h.Reset()
+	assert.Equal(t, Checksum(nil), h.Sum16())
+}
+
+// referenceChecksum is the bit-by-bit CRC16 Modbus algorithm.
+func referenceChecksum(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if (crc & 0x0001) != 0 {
+				crc >>= 1
+				crc ^= 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func FuzzChecksum(f *testing.F) {
+	for _, tt := range specPackets {
+		raw, _ := hex.DecodeString(strings.ReplaceAll(tt.hex, " ", ""))
+		f.Add(raw, 3)
+	}
+	f.Add([]byte{}, 0)
+
+	f.Fuzz(func(t *testing.T, data []byte, split int) {
+		want := referenceChecksum(data)
+		if got := Checksum(data); got != want {
+			t.Fatalf("Checksum(%x) = %04X, reference %04X", data, got, want)
+		}
+
+		// Any split of the input gives the same streaming result
+		if split < 0 {
+			split = -split
+		}
+		split %= len(data) + 1
+		h := NewCRC16()
+		h.Write(data[:split])
+		h.Write(data[split:])
+		if got := h.Sum16(); got != want {
+			t.Fatalf("CRC16 split at %d = %04X, reference %04X", split, got, want)
+		}
+	})
+}
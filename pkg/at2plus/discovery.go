@@ -2,112 +2,582 @@ package at2plus
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"net"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
 // DiscoveryResult represents a discovered AirTouch device
 type DiscoveryResult struct {
-	IP string
+	IP   string `json:"ip"`
+	Port int    `json:"port"`
+
+	// MAC is the MAC address of the device, from the kernel neighbour
+	// table. It is only known for devices on the local network, on Linux.
+	MAC string `json:"mac,omitempty"`
+	// Vendor is a hint of who made the device's network interface,
+	// derived from the MAC address. It is empty if the vendor is unknown.
+	Vendor string `json:"vendor,omitempty"`
+	// DeviceID identifies the device across IP address changes:
+	// "mac:" and the MAC address in hex, or "console:" and the console ID
+	// if the MAC address is not known. It can be passed to NewClient.
+	DeviceID string `json:"device_id,omitempty"`
+
+	// The fields below are only set for devices that answered a
+	// broadcast, see WithBroadcast.
+
+	// ConsoleID is the serial number of the touch console.
+	ConsoleID string `json:"console_id,omitempty"`
+	// DeviceType is the model reported by the console, e.g. "AirTouch2+".
+	DeviceType string `json:"device_type,omitempty"`
+	// AirTouchID is the system ID shown in the AirTouch app.
+	AirTouchID string `json:"airtouch_id,omitempty"`
+	// Name is the system name, if the console reports one.
+	Name string `json:"name,omitempty"`
+
+	// The fields below are only set when discovery verifies devices
+	// with WithHandshake.
+
+	// Verified reports that the device answered a group status query
+	// with a valid AirTouch 2+ response.
+	Verified bool `json:"verified"`
+	// GroupCount is the number of zones (groups) of the system.
+	GroupCount int `json:"group_count,omitempty"`
+	// ACCount is the number of ACs of the system.
+	ACCount int `json:"ac_count,omitempty"`
+	// ACNames are the names of the ACs, indexed by AC number order.
+	ACNames []string `json:"ac_names,omitempty"`
+	// Latency is the round-trip time of the group status query.
+	// It is encoded in JSON in nanoseconds.
+	Latency time.Duration `json:"latency_ns,omitempty"`
+}
+
+// Discovery defaults.
+const (
+	DefaultDiscoverTimeout     = 3 * time.Second
+	DefaultDiscoverDialTimeout = 200 * time.Millisecond
+	DefaultDiscoverWorkers     = 64
+	DefaultBroadcastWait       = time.Second
+)
+
+// ErrDeviceNotFound is returned by ResolveDevice, and NewClient given a
+// MAC address or device ID, when discovery does not find the device.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// maxDiscoverHostBits limits a CIDR target to 2^16 addresses. Local
+// networks larger than that are scanned around the local address only.
+const maxDiscoverHostBits = 16
+
+// DiscoverOption configures Discover.
+type DiscoverOption func(*discoverConfig) error
+
+// discoverConfig holds the configuration for Discover.
+type discoverConfig struct {
+	handshake   bool
+	targets     []netip.Prefix
+	ranges      [][2]netip.Addr
+	interfaces  []string
+	ports       []int
+	dialTimeout time.Duration
+	workers     int
+
+	portScan       bool
+	broadcast      bool
+	broadcastAddrs []netip.AddrPort
+	broadcastWait  time.Duration
+}
+
+// defaultDiscoverConfig returns the default discovery configuration.
+func defaultDiscoverConfig() *discoverConfig {
+	return &discoverConfig{
+		ports:         []int{9200},
+		dialTimeout:   DefaultDiscoverDialTimeout,
+		workers:       DefaultDiscoverWorkers,
+		portScan:      true,
+		broadcastWait: DefaultBroadcastWait,
+	}
+}
+
+// WithHandshake makes discovery talk to every host that accepts a
+// connection: only hosts that answer a group status query with a valid
+// AirTouch 2+ response are reported, together with their group and AC
+// counts, AC names and latency. Without it, any host with the port open
+// is reported.
+func WithHandshake() DiscoverOption {
+	return func(c *discoverConfig) error {
+		c.handshake = true
+		return nil
+	}
+}
+
+// WithTargets scans the given addresses instead of the local networks.
+// Each target is a single IP ("192.168.1.50"), a CIDR ("10.1.0.0/22") or
+// an inclusive range ("192.168.1.10-192.168.1.60"). Network and broadcast
+// addresses of IPv4 CIDRs are skipped.
+func WithTargets(targets ...string) DiscoverOption {
+	return func(c *discoverConfig) error {
+		for _, t := range targets {
+			t = strings.TrimSpace(t)
+			if from, to, ok := strings.Cut(t, "-"); ok {
+				lo, err := netip.ParseAddr(strings.TrimSpace(from))
+				if err != nil {
+					return fmt.Errorf("invalid range %q: %w", t, err)
+				}
+				hi, err := netip.ParseAddr(strings.TrimSpace(to))
+				if err != nil {
+					return fmt.Errorf("invalid range %q: %w", t, err)
+				}
+				if lo.BitLen() != hi.BitLen() || hi.Less(lo) {
+					return fmt.Errorf("invalid range %q", t)
+				}
+				c.ranges = append(c.ranges, [2]netip.Addr{lo, hi})
+				continue
+			}
+
+			if strings.Contains(t, "/") {
+				p, err := netip.ParsePrefix(t)
+				if err != nil {
+					return fmt.Errorf("invalid CIDR %q: %w", t, err)
+				}
+				if p.Addr().BitLen()-p.Bits() > maxDiscoverHostBits {
+					return fmt.Errorf("CIDR %q is larger than /%d", t, p.Addr().BitLen()-maxDiscoverHostBits)
+				}
+				c.targets = append(c.targets, p.Masked())
+				continue
+			}
+
+			a, err := netip.ParseAddr(t)
+			if err != nil {
+				return fmt.Errorf("invalid target %q: %w", t, err)
+			}
+			c.targets = append(c.targets, netip.PrefixFrom(a, a.BitLen()))
+		}
+		return nil
+	}
+}
+
+// WithInterfaces limits the scan of local networks to the named network
+// interfaces, e.g. "eth0". It has no effect together with WithTargets.
+func WithInterfaces(names ...string) DiscoverOption {
+	return func(c *discoverConfig) error {
+		c.interfaces = append(c.interfaces, names...)
+		return nil
+	}
 }
 
-// Discover searches for AirTouch 2+ devices on the network.
-// It scans the local subnet on port 9200.
+// WithPorts sets the TCP ports probed on each host.
+// Default is 9200.
+func WithPorts(ports ...int) DiscoverOption {
+	return func(c *discoverConfig) error {
+		if len(ports) == 0 {
+			return errors.New("at least one port is required")
+		}
+		for _, port := range ports {
+			if port < 1 || port > 65535 {
+				return errors.New("port must be between 1 and 65535")
+			}
+		}
+		c.ports = ports
+		return nil
+	}
+}
+
+// WithDialTimeout sets the timeout for each connection attempt.
+// Default is DefaultDiscoverDialTimeout.
+func WithDialTimeout(d time.Duration) DiscoverOption {
+	return func(c *discoverConfig) error {
+		if d <= 0 {
+			return errors.New("dial timeout must be positive")
+		}
+		c.dialTimeout = d
+		return nil
+	}
+}
+
+// WithWorkers sets how many hosts are probed at the same time.
+// Default is DefaultDiscoverWorkers.
+func WithWorkers(n int) DiscoverOption {
+	return func(c *discoverConfig) error {
+		if n < 1 {
+			return errors.New("workers must be at least 1")
+		}
+		c.workers = n
+		return nil
+	}
+}
+
+// WithBroadcast also sends a UDP discovery broadcast to the local
+// networks on DefaultBroadcastPorts and reports every console that
+// answers, with its console ID and device type. Consoles are reported on
+// the first of the scanned ports. Results for a host found by both the
+// broadcast and the port scan are merged.
+//
+// Replies are awaited for DefaultBroadcastWait after the broadcast is
+// sent, see WithBroadcastWait.
+func WithBroadcast() DiscoverOption {
+	return func(c *discoverConfig) error {
+		c.broadcast = true
+		return nil
+	}
+}
+
+// WithBroadcastAddrs sends the discovery broadcast to the given
+// "host:port" addresses instead of the local broadcast addresses, e.g.
+// to reach a console behind a router. It implies WithBroadcast.
+func WithBroadcastAddrs(addrs ...string) DiscoverOption {
+	return func(c *discoverConfig) error {
+		for _, a := range addrs {
+			ap, err := netip.ParseAddrPort(strings.TrimSpace(a))
+			if err != nil {
+				return fmt.Errorf("invalid broadcast address %q: %w", a, err)
+			}
+			if !ap.Addr().Is4() {
+				return fmt.Errorf("broadcast address %q is not IPv4", a)
+			}
+			c.broadcastAddrs = append(c.broadcastAddrs, ap)
+		}
+		c.broadcast = true
+		return nil
+	}
+}
+
+// WithBroadcastWait sets how long broadcast replies are awaited after the
+// broadcast is sent. Default is DefaultBroadcastWait.
+func WithBroadcastWait(d time.Duration) DiscoverOption {
+	return func(c *discoverConfig) error {
+		if d <= 0 {
+			return errors.New("broadcast wait must be positive")
+		}
+		c.broadcastWait = d
+		return nil
+	}
+}
+
+// WithoutPortScan skips the TCP port scan, leaving only the broadcast.
+func WithoutPortScan() DiscoverOption {
+	return func(c *discoverConfig) error {
+		c.portScan = false
+		return nil
+	}
+}
+
+// Discover searches for AirTouch 2+ devices on the network and returns
+// all devices found. By default it scans the local networks on port 9200.
 // The context controls the overall discovery timeout.
 // If the context has no deadline, a 3-second timeout is applied.
-func Discover(ctx context.Context) ([]DiscoveryResult, error) {
+func Discover(ctx context.Context, opts ...DiscoverOption) ([]DiscoveryResult, error) {
 	var results []DiscoveryResult
+	index := make(map[string]int)
+	err := DiscoverEach(ctx, func(res DiscoveryResult) {
+		if i, ok := index[res.IP]; ok {
+			results[i] = res
+			return
+		}
+		index[res.IP] = len(results)
+		results = append(results, res)
+	}, opts...)
+	return results, err
+}
+
+// DiscoverEach is like Discover but calls fn for each device as soon as
+// it is found. fn is called from the calling goroutine, never after
+// DiscoverEach returns. Discovery ends when all hosts have been probed
+// and the broadcast wait is over, or the context is done; neither is an
+// error.
+//
+// With WithBroadcast, fn may be called again for a device already
+// reported, with the details learned since merged in.
+func DiscoverEach(ctx context.Context, fn func(DiscoveryResult), opts ...DiscoverOption) error {
+	cfg := defaultDiscoverConfig()
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return fmt.Errorf("invalid option: %w", err)
+		}
+	}
 
 	// Apply default timeout if context has no deadline
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, 3*time.Second)
+		ctx, cancel = context.WithTimeout(ctx, DefaultDiscoverTimeout)
 		defer cancel()
 	}
 
-	// Find local IP and scan /24
-	ips, err := getLocalIPs()
-	if err != nil {
-		return nil, fmt.Errorf("get local IPs: %w", err)
+	if !cfg.portScan && !cfg.broadcast {
+		return errors.New("invalid option: port scan and broadcast both disabled")
 	}
 
-	type scanResult struct {
-		ip string
-		ok bool
+	resultsCh := make(chan DiscoveryResult)
+	var (
+		wg           sync.WaitGroup
+		scanErr      error
+		broadcastErr error
+	)
+	if cfg.portScan {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanErr = portScan(ctx, cfg, resultsCh)
+		}()
 	}
-
-	// Count total IPs to scan
-	count := 0
-	for range ips {
-		count += 254 // 1-254 for each /24 subnet
-	}
-
-	// Use buffered channel to prevent goroutine leaks
-	resultsCh := make(chan scanResult, count)
-	var wg sync.WaitGroup
-
-	for _, ip := range ips {
-		// Assume /24 subnet
-		// e.g. 192.168.1.x
-		baseIP := ip.Mask(net.CIDRMask(24, 32))
-		baseIP[3] = 0
-
-		for i := 1; i < 255; i++ {
-			targetIP := net.IP{baseIP[0], baseIP[1], baseIP[2], byte(i)}
-			wg.Add(1)
-			go func(ip string) {
-				defer wg.Done()
-				var d net.Dialer
-				dialCtx, dialCancel := context.WithTimeout(ctx, 200*time.Millisecond)
-				defer dialCancel()
-				conn, err := d.DialContext(dialCtx, "tcp", net.JoinHostPort(ip, "9200"))
-				if err == nil {
-					conn.Close()
-					resultsCh <- scanResult{ip: ip, ok: true}
-				} else {
-					resultsCh <- scanResult{ip: ip, ok: false}
-				}
-			}(targetIP.String())
-		}
+	if cfg.broadcast {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := broadcastDiscover(ctx, cfg, resultsCh); err != nil {
+				broadcastErr = fmt.Errorf("broadcast: %w", err)
+			}
+		}()
 	}
 
-	// Close channel when all goroutines complete
+	// Close channel when all sources complete
 	go func() {
 		wg.Wait()
 		close(resultsCh)
 	}()
 
-	// Collect results until channel is closed or context is done
+	// A host found again is reported again only if it gained details
+	seen := make(map[string]DiscoveryResult)
+	var arp map[string]net.HardwareAddr
 	for res := range resultsCh {
-		if res.ok {
-			results = append(results, DiscoveryResult{IP: res.ip})
+		old, ok := seen[res.IP]
+		if ok {
+			res = mergeResult(old, res)
+		}
+		identify(&res, &arp)
+		if ok && reflect.DeepEqual(old, res) {
+			continue
 		}
-		// Check context between results
-		select {
-		case <-ctx.Done():
-			return results, nil
-		default:
+		seen[res.IP] = res
+		fn(res)
+	}
+	return errors.Join(scanErr, broadcastErr)
+}
+
+// portScan probes every target host and port and sends the devices found
+// to out. It returns when all hosts have been probed or ctx is done.
+func portScan(ctx context.Context, cfg *discoverConfig, out chan<- DiscoveryResult) error {
+	targets, ranges := cfg.targets, cfg.ranges
+	if len(targets) == 0 && len(ranges) == 0 {
+		var err error
+		targets, err = localNetworks(cfg.interfaces)
+		if err != nil {
+			return fmt.Errorf("get local networks: %w", err)
 		}
 	}
 
-	return results, nil
+	type probe struct {
+		ip   netip.Addr
+		port int
+	}
+	probes := make(chan probe)
+
+	// Feed every host and port to the workers
+	go func() {
+		defer close(probes)
+		send := func(ip netip.Addr) bool {
+			for _, port := range cfg.ports {
+				select {
+				case probes <- probe{ip: ip, port: port}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+		for _, p := range targets {
+			for ip := range prefixHosts(p) {
+				if !send(ip) {
+					return
+				}
+			}
+		}
+		for _, r := range ranges {
+			for ip := r[0]; ip.IsValid() && !r[1].Less(ip); ip = ip.Next() {
+				if !send(ip) {
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range cfg.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range probes {
+				res, ok := probeHost(ctx, cfg, p.ip.String(), p.port)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- res:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
 }
 
-func getLocalIPs() ([]net.IP, error) {
-	addrs, err := net.InterfaceAddrs()
+// mergeResult fills the fields of a that are not set from b.
+func mergeResult(a, b DiscoveryResult) DiscoveryResult {
+	if a.Port == 0 {
+		a.Port = b.Port
+	}
+	if a.MAC == "" {
+		a.MAC, a.Vendor = b.MAC, b.Vendor
+	}
+	if a.ConsoleID == "" {
+		a.ConsoleID = b.ConsoleID
+	}
+	if a.DeviceType == "" {
+		a.DeviceType = b.DeviceType
+	}
+	if a.AirTouchID == "" {
+		a.AirTouchID = b.AirTouchID
+	}
+	if a.Name == "" {
+		a.Name = b.Name
+	}
+	if !a.Verified && b.Verified {
+		a.Verified = true
+		a.GroupCount = b.GroupCount
+		a.ACCount = b.ACCount
+		a.ACNames = b.ACNames
+		a.Latency = b.Latency
+	}
+	return a
+}
+
+// probeHost checks whether a device listens on ip:port and, with
+// WithHandshake, whether it is an AirTouch 2+.
+func probeHost(ctx context.Context, cfg *discoverConfig, ip string, port int) (DiscoveryResult, bool) {
+	if cfg.handshake {
+		res, err := handshake(ctx, ip, port, cfg.dialTimeout)
+		return res, err == nil
+	}
+
+	var d net.Dialer
+	dialCtx, dialCancel := context.WithTimeout(ctx, cfg.dialTimeout)
+	defer dialCancel()
+	conn, err := d.DialContext(dialCtx, "tcp", net.JoinHostPort(ip, fmt.Sprint(port)))
+	if err != nil {
+		return DiscoveryResult{}, false
+	}
+	conn.Close()
+	return DiscoveryResult{IP: ip, Port: port}, true
+}
+
+// prefixHosts yields the host addresses of p. For IPv4 networks larger
+// than /31 the network and broadcast addresses are left out.
+func prefixHosts(p netip.Prefix) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		first := p.Masked().Addr()
+		skipEnds := first.Is4() && p.Bits() < 31
+		for ip := first; ip.IsValid() && p.Contains(ip); ip = ip.Next() {
+			if skipEnds && (ip == first || !p.Contains(ip.Next())) {
+				continue
+			}
+			if !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+// localNetworks returns the IPv4 networks of the up, non-loopback
+// interfaces, optionally limited to the named ones. Networks larger than
+// a /16 are narrowed to the /16 around the local address.
+func localNetworks(names []string) ([]netip.Prefix, error) {
+	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, err
 	}
 
-	var ips []net.IP
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				ips = append(ips, ipnet.IP)
+	var nets []netip.Prefix
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if len(names) > 0 && !slices.Contains(names, iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			ip, _ := netip.AddrFromSlice(ipnet.IP.To4())
+			ones, _ := ipnet.Mask.Size()
+			p := netip.PrefixFrom(ip, max(ones, 32-maxDiscoverHostBits)).Masked()
+			if !slices.Contains(nets, p) {
+				nets = append(nets, p)
+			}
+		}
+	}
+	return nets, nil
+}
+
+// handshakeTimeout bounds each step of the discovery handshake.
+// A host that is not an AirTouch usually never answers at all.
+const handshakeTimeout = time.Second
+
+// handshake verifies that the device at ip:port speaks the AirTouch 2+
+// protocol and describes its system.
+func handshake(ctx context.Context, ip string, port int, dialTimeout time.Duration) (DiscoveryResult, error) {
+	dialCtx, dialCancel := context.WithTimeout(ctx, dialTimeout)
+	defer dialCancel()
+	client, err := NewClient(dialCtx, ip, WithPort(port))
+	if err != nil {
+		return DiscoveryResult{}, err
+	}
+	defer client.Close()
+
+	verifyCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	start := time.Now()
+	groups, err := client.GetGroupStatus(verifyCtx)
+	if err != nil {
+		return DiscoveryResult{}, err
+	}
+	res := DiscoveryResult{
+		IP:         ip,
+		Port:       port,
+		Verified:   true,
+		GroupCount: len(groups),
+		Latency:    time.Since(start),
+	}
+
+	// The device is verified; missing details do not make it less so
+	detailCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
+	defer cancel()
+	acs, err := client.GetACStatus(detailCtx)
+	if err != nil {
+		return res, nil
+	}
+	res.ACCount = len(acs)
+	for _, ac := range acs {
+		name := ""
+		abilities, _ := client.GetACAbility(detailCtx, ac.ACNumber)
+		for _, a := range abilities {
+			if a.ACNumber == ac.ACNumber {
+				name = a.Name
 			}
 		}
+		res.ACNames = append(res.ACNames, name)
 	}
-	return ips, nil
+	return res, nil
 }
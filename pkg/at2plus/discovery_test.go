@@ -0,0 +1,134 @@
+package at2plus
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// answerHandshake serves the requests of a discovery handshake.
+func answerHandshake(t *testing.T, conn net.Conn) {
+	acs, err := MarshalACStatus([]ACStatus{
+		{ACNumber: 0, Setpoint: Celsius(22)},
+		{ACNumber: 1, Setpoint: Celsius(22)},
+	})
+	require.NoError(t, err)
+
+	for {
+		req := readRequest(t, conn)
+		switch {
+		case req.Data[0] == SubMsgTypeGroupStatus:
+			writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, specGroupStatusData)
+		case req.Data[0] == SubMsgTypeACStatus:
+			writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, acs)
+		case req.MsgType == MsgTypeExtended && req.Data[1] == ExtMsgTypeACAbility:
+			name := map[uint8]string{0: "UNIT", 1: "Upstairs"}[req.Data[2]]
+			data, err := MarshalACAbility([]ACAbility{{ACNumber: req.Data[2], Name: name}})
+			require.NoError(t, err)
+			writeResponse(t, conn, req.MsgID, MsgTypeExtended, data)
+			if req.Data[2] == 1 {
+				return
+			}
+		}
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	dev := newFakeDevice(t)
+	go func() { answerHandshake(t, dev.accept(t)) }()
+
+	port := dev.ln.Addr().(*net.TCPAddr).Port
+	res, err := handshake(context.Background(), "127.0.0.1", port, time.Second)
+	require.NoError(t, err)
+	assert.True(t, res.Verified)
+	assert.Equal(t, "127.0.0.1", res.IP)
+	assert.Equal(t, 2, res.GroupCount)
+	assert.Equal(t, 2, res.ACCount)
+	assert.Equal(t, []string{"UNIT", "Upstairs"}, res.ACNames)
+	assert.Positive(t, res.Latency)
+}
+
+func TestHandshake_NotAnAirTouch(t *testing.T) {
+	dev := newFakeDevice(t)
+	go func() {
+		conn := dev.accept(t)
+		readRequest(t, conn)
+		_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+	}()
+
+	port := dev.ln.Addr().(*net.TCPAddr).Port
+	_, err := handshake(context.Background(), "127.0.0.1", port, time.Second)
+	assert.Error(t, err)
+}
+
+func TestWithTargets(t *testing.T) {
+	cfg := defaultDiscoverConfig()
+	err := WithTargets("192.168.1.50", "10.1.0.0/22", "192.168.2.10-192.168.2.12")(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.168.1.50/32"),
+		netip.MustParsePrefix("10.1.0.0/22"),
+	}, cfg.targets)
+	assert.Equal(t, [][2]netip.Addr{{
+		netip.MustParseAddr("192.168.2.10"),
+		netip.MustParseAddr("192.168.2.12"),
+	}}, cfg.ranges)
+
+	for _, bad := range []string{"host.local", "10.0.0.0/33", "10.0.0.0/8", "10.0.0.9-10.0.0.1", "10.0.0.1-::1"} {
+		assert.Error(t, WithTargets(bad)(defaultDiscoverConfig()), bad)
+	}
+}
+
+func TestDiscoverOptions_Invalid(t *testing.T) {
+	cfg := defaultDiscoverConfig()
+	assert.Error(t, WithPorts()(cfg))
+	assert.Error(t, WithPorts(0)(cfg))
+	assert.Error(t, WithDialTimeout(0)(cfg))
+	assert.Error(t, WithWorkers(0)(cfg))
+	assert.Error(t, WithBroadcastWait(0)(cfg))
+}
+
+func TestPrefixHosts(t *testing.T) {
+	count := func(p string) int {
+		return len(slices.Collect(prefixHosts(netip.MustParsePrefix(p))))
+	}
+	assert.Equal(t, 1022, count("10.1.0.0/22"))
+	assert.Equal(t, 254, count("192.168.1.0/24"))
+	assert.Equal(t, 2, count("192.168.1.0/31"))
+	assert.Equal(t, 1, count("192.168.1.7/32"))
+
+	hosts := slices.Collect(prefixHosts(netip.MustParsePrefix("192.168.1.0/30")))
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("192.168.1.1"), netip.MustParseAddr("192.168.1.2")}, hosts)
+}
+
+func TestDiscoverEach_TargetsAndPorts(t *testing.T) {
+	dev := newFakeDevice(t)
+	go func() { answerHandshake(t, dev.accept(t)) }()
+	port := dev.ln.Addr().(*net.TCPAddr).Port
+
+	var found []DiscoveryResult
+	err := DiscoverEach(context.Background(), func(res DiscoveryResult) {
+		found = append(found, res)
+	}, WithTargets("127.0.0.1"), WithPorts(port), WithHandshake(), WithWorkers(2))
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "127.0.0.1", found[0].IP)
+	assert.Equal(t, port, found[0].Port)
+	assert.True(t, found[0].Verified)
+}
+
+func TestDiscover_PortOpenOnly(t *testing.T) {
+	dev := newFakeDevice(t)
+	port := dev.ln.Addr().(*net.TCPAddr).Port
+
+	results, err := Discover(context.Background(), WithTargets("127.0.0.1-127.0.0.1"), WithPorts(port))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Verified)
+}
@@ -25,6 +25,172 @@
 //	    at2plus.WithLogger(slog.Default()),
 //	)
 //
+// The client is safe for concurrent use. Requests are queued and written
+// by a single goroutine; by default only one awaits a response at a time
+// (see WithMaxInFlight), and control commands are sent ahead of queued
+// status queries.
+//
+// # Errors
+//
+// Errors are wrapped with context; use errors.Is to tell them apart:
+//
+//	switch {
+//	case errors.Is(err, at2plus.ErrTimeout):
+//	    // the device did not answer in time
+//	case errors.Is(err, at2plus.ErrConnectionLost):
+//	    // the connection dropped while the request was in flight
+//	case errors.Is(err, at2plus.ErrClosed):
+//	    // the client was closed
+//	case errors.Is(err, at2plus.ErrProtocol):
+//	    // the device sent a malformed message
+//	}
+//
+// # Reconnecting
+//
+// By default a dropped connection closes the client. For long-running
+// services, enable reconnecting mode so the client redials with
+// exponential backoff and reports state transitions:
+//
+//	client, err := at2plus.NewClient(ctx, "192.168.1.50",
+//	    at2plus.WithReconnect(time.Second, time.Minute),
+//	    at2plus.WithPendingPolicy(at2plus.ReplayPending),
+//	    at2plus.WithStateHandler(func(s at2plus.ConnState, err error) {
+//	        log.Printf("connection %s: %v", s, err)
+//	    }),
+//	)
+//
+// # Discovery
+//
+// Discover finds devices by scanning the local networks and, with
+// WithBroadcast, by asking consoles to announce themselves over UDP.
+// Each result carries a DeviceID derived from the MAC address, which
+// stays the same when DHCP moves the device. NewClient accepts it in
+// place of an IP and finds the device's current address, also when a
+// reconnecting client loses it:
+//
+//	client, err := at2plus.NewClient(ctx, "mac:a4cf12bd3e01",
+//	    at2plus.WithReconnect(time.Second, time.Minute),
+//	)
+//
+// # Confirmation
+//
+// The device acknowledges a control command before acting on it, and
+// occasionally does not act on it at all. With WithConfirm, SetGroupControl
+// and SetACControl wait until the status the device reports matches the
+// command, and return a *NotAppliedError otherwise:
+//
+//	client, err := at2plus.NewClient(ctx, "192.168.1.50",
+//	    at2plus.WithConfirm(10*time.Second),
+//	)
+//
+//	err = client.SetACControl(ctx, []at2plus.ACControl{
+//	    {ACNumber: 0, Power: at2plus.Ptr(at2plus.ACPowerOff)},
+//	})
+//	var nae *at2plus.NotAppliedError
+//	if errors.As(err, &nae) {
+//	    for _, d := range nae.Divergences {
+//	        fmt.Println(d.Field, "is", d.Got, "not", d.Want)
+//	    }
+//	}
+//
+// # Names
+//
+// Groups and ACs can be addressed by the names shown on the console as
+// well as by number. ResolveGroup and ResolveAC match names
+// case-insensitively, in full or by a unique prefix, and SetGroup and
+// SetAC send a command to the unit a name refers to:
+//
+//	err := client.SetGroup(ctx, "kitchen", at2plus.GroupControl{
+//	    Percent: at2plus.Ptr(50),
+//	})
+//	var ae *at2plus.AmbiguousNameError
+//	switch {
+//	case errors.Is(err, at2plus.ErrUnknownName):
+//	    // no group has that name
+//	case errors.As(err, &ae):
+//	    // several groups match, e.g. "bed" for "Bed 1" and "Bed 2"
+//	}
+//
+// # Batches
+//
+// The control messages carry any number of groups or ACs. A Batch merges
+// the changes given to it into as few packets as possible, usually one
+// for groups and one for ACs:
+//
+//	err := client.Batch().
+//	    Group(at2plus.GroupControl{GroupNumber: 1, Power: at2plus.Ptr(at2plus.GroupPowerOn), Percent: at2plus.Ptr(60)}).
+//	    Group(at2plus.GroupControl{GroupNumber: 3, Power: at2plus.Ptr(at2plus.GroupPowerOff)}).
+//	    Send(ctx)
+//
+// A Coalescer collects changes the same way and sends them once they have
+// been quiet for a while, so a UI can pass on every step of a slider
+// without flooding the device:
+//
+//	co, err := client.Coalesce(200*time.Millisecond,
+//	    at2plus.WithMaxDelay(time.Second),
+//	    at2plus.WithErrorHandler(func(err error) { log.Print(err) }),
+//	)
+//	defer co.Close()
+//	co.Group(at2plus.GroupControl{GroupNumber: 1, Percent: at2plus.Ptr(pct)})
+//
+// # Status Updates
+//
+// The device pushes group and AC status messages on its own whenever
+// something changes, for example from the wall console. Subscribe to
+// receive them instead of polling:
+//
+//	sub, err := client.Subscribe(ctx, at2plus.WithBufferSize(32))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for u := range sub.Updates() {
+//	    for _, g := range u.Groups {
+//	        fmt.Printf("group %d is now %d%%\n", g.GroupNumber, g.Percent)
+//	    }
+//	}
+//
+// # State
+//
+// A StateStore keeps the latest view of the whole system, merging status,
+// group names and AC abilities, and turns the pushed updates into typed
+// change events:
+//
+//	store, err := at2plus.NewStateStore(ctx, client)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer store.Close()
+//
+//	snap := store.Snapshot()
+//	for _, g := range snap.Groups {
+//	    fmt.Printf("%s: %s since %s\n", g.Name, g.Power, g.Since["Power"])
+//	}
+//	for ev := range store.Watch(ctx) {
+//	    if ev, ok := ev.(at2plus.ACSetpointChanged); ok {
+//	        fmt.Printf("AC %d setpoint %s -> %s\n", ev.AC, ev.Old, ev.New)
+//	    }
+//	}
+//
+// # Validation
+//
+// The device silently ignores settings an AC does not support. With
+// WithValidation, SetACControl checks each command against the abilities
+// the AC reports and returns a *ValidationError listing the offending fields:
+//
+//	client, err := at2plus.NewClient(ctx, "192.168.1.50",
+//	    at2plus.WithValidation(at2plus.ValidateReject),
+//	)
+//
+//	err = client.SetACControl(ctx, []at2plus.ACControl{
+//	    {ACNumber: 0, Mode: at2plus.Ptr(at2plus.ACModeHeat)},
+//	})
+//	var ve *at2plus.ValidationError
+//	if errors.As(err, &ve) {
+//	    for _, fe := range ve.Errors {
+//	        fmt.Println(fe.Field, fe.Reason)
+//	    }
+//	}
+//
 // # Protocol
 //
 // This package implements the AirTouch 2+ Communication Protocol v1.1.
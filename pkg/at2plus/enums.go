@@ -0,0 +1,413 @@
+package at2plus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The enum types below use the values from the protocol spec, so they can be
+// written to and read from the wire without translation. Control commands
+// and reported status use different numbering, hence separate types for
+// each direction.
+
+// GroupPowerCommand is the power setting sent in a group control message.
+type GroupPowerCommand uint8
+
+const (
+	GroupPowerNext  GroupPowerCommand = 1 // Change to next state
+	GroupPowerOff   GroupPowerCommand = 2
+	GroupPowerOn    GroupPowerCommand = 3
+	GroupPowerTurbo GroupPowerCommand = 5
+)
+
+var groupPowerCommandNames = map[GroupPowerCommand]string{
+	GroupPowerNext:  "next",
+	GroupPowerOff:   "off",
+	GroupPowerOn:    "on",
+	GroupPowerTurbo: "turbo",
+}
+
+// GroupPowerState is the power state reported in a group status message.
+type GroupPowerState uint8
+
+const (
+	GroupPowerStateOff   GroupPowerState = 0
+	GroupPowerStateOn    GroupPowerState = 1
+	GroupPowerStateTurbo GroupPowerState = 3
+)
+
+var groupPowerStateNames = map[GroupPowerState]string{
+	GroupPowerStateOff:   "off",
+	GroupPowerStateOn:    "on",
+	GroupPowerStateTurbo: "turbo",
+}
+
+// GroupValue is the setting value action sent in a group control message.
+type GroupValue uint8
+
+const (
+	GroupValueDecrease GroupValue = 2 // -5%
+	GroupValueIncrease GroupValue = 3 // +5%
+	GroupValueSet      GroupValue = 4 // Set open percentage
+)
+
+var groupValueNames = map[GroupValue]string{
+	GroupValueDecrease: "decrease",
+	GroupValueIncrease: "increase",
+	GroupValueSet:      "set",
+}
+
+var groupValueAliases = map[string]GroupValue{
+	"dec": GroupValueDecrease,
+	"inc": GroupValueIncrease,
+}
+
+// ACPowerCommand is the power setting sent in an AC control message.
+type ACPowerCommand uint8
+
+const (
+	ACPowerToggle ACPowerCommand = 1 // Change on/off status
+	ACPowerOff    ACPowerCommand = 2
+	ACPowerOn     ACPowerCommand = 3
+	ACPowerAway   ACPowerCommand = 4
+	ACPowerSleep  ACPowerCommand = 5
+)
+
+var acPowerCommandNames = map[ACPowerCommand]string{
+	ACPowerToggle: "toggle",
+	ACPowerOff:    "off",
+	ACPowerOn:     "on",
+	ACPowerAway:   "away",
+	ACPowerSleep:  "sleep",
+}
+
+// ACPowerState is the power state reported in an AC status message.
+type ACPowerState uint8
+
+const (
+	ACPowerStateOff     ACPowerState = 0
+	ACPowerStateOn      ACPowerState = 1
+	ACPowerStateAwayOff ACPowerState = 2
+	ACPowerStateAwayOn  ACPowerState = 3
+	ACPowerStateSleep   ACPowerState = 5
+)
+
+var acPowerStateNames = map[ACPowerState]string{
+	ACPowerStateOff:     "off",
+	ACPowerStateOn:      "on",
+	ACPowerStateAwayOff: "away-off",
+	ACPowerStateAwayOn:  "away-on",
+	ACPowerStateSleep:   "sleep",
+}
+
+// IsOn reports whether the AC is running in this state.
+func (s ACPowerState) IsOn() bool {
+	return s == ACPowerStateOn || s == ACPowerStateAwayOn || s == ACPowerStateSleep
+}
+
+// ACMode is the AC operating mode. ACModeAutoHeat and ACModeAutoCool are
+// only reported in status; control accepts ACModeAuto through ACModeCool.
+type ACMode uint8
+
+const (
+	ACModeAuto     ACMode = 0
+	ACModeHeat     ACMode = 1
+	ACModeDry      ACMode = 2
+	ACModeFan      ACMode = 3
+	ACModeCool     ACMode = 4
+	ACModeAutoHeat ACMode = 8
+	ACModeAutoCool ACMode = 9
+)
+
+var acModeNames = map[ACMode]string{
+	ACModeAuto:     "auto",
+	ACModeHeat:     "heat",
+	ACModeDry:      "dry",
+	ACModeFan:      "fan",
+	ACModeCool:     "cool",
+	ACModeAutoHeat: "auto-heat",
+	ACModeAutoCool: "auto-cool",
+}
+
+// FanSpeed is the AC fan speed, used in both control and status.
+type FanSpeed uint8
+
+const (
+	FanSpeedAuto     FanSpeed = 0
+	FanSpeedQuiet    FanSpeed = 1
+	FanSpeedLow      FanSpeed = 2
+	FanSpeedMedium   FanSpeed = 3
+	FanSpeedHigh     FanSpeed = 4
+	FanSpeedPowerful FanSpeed = 5
+	FanSpeedTurbo    FanSpeed = 6
+)
+
+var fanSpeedNames = map[FanSpeed]string{
+	FanSpeedAuto:     "auto",
+	FanSpeedQuiet:    "quiet",
+	FanSpeedLow:      "low",
+	FanSpeedMedium:   "medium",
+	FanSpeedHigh:     "high",
+	FanSpeedPowerful: "powerful",
+	FanSpeedTurbo:    "turbo",
+}
+
+var fanSpeedAliases = map[string]FanSpeed{
+	"med": FanSpeedMedium,
+}
+
+// Ptr returns a pointer to v. It is handy for filling the optional fields
+// of GroupControl and ACControl:
+//
+//	at2plus.ACControl{ACNumber: 0, Mode: at2plus.Ptr(at2plus.ACModeCool)}
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// ParseGroupPowerCommand parses "next", "off", "on" or "turbo".
+func ParseGroupPowerCommand(s string) (GroupPowerCommand, error) {
+	return parseEnum(s, groupPowerCommandNames, nil, "group power command")
+}
+
+// ParseGroupPowerState parses "off", "on" or "turbo".
+func ParseGroupPowerState(s string) (GroupPowerState, error) {
+	return parseEnum(s, groupPowerStateNames, nil, "group power state")
+}
+
+// ParseGroupValue parses "decrease" ("dec"), "increase" ("inc") or "set".
+func ParseGroupValue(s string) (GroupValue, error) {
+	return parseEnum(s, groupValueNames, groupValueAliases, "group value")
+}
+
+// ParseACPowerCommand parses "toggle", "off", "on", "away" or "sleep".
+func ParseACPowerCommand(s string) (ACPowerCommand, error) {
+	return parseEnum(s, acPowerCommandNames, nil, "AC power command")
+}
+
+// ParseACPowerState parses "off", "on", "away-off", "away-on" or "sleep".
+func ParseACPowerState(s string) (ACPowerState, error) {
+	return parseEnum(s, acPowerStateNames, nil, "AC power state")
+}
+
+// ParseACMode parses "auto", "heat", "dry", "fan", "cool", "auto-heat" or "auto-cool".
+func ParseACMode(s string) (ACMode, error) {
+	return parseEnum(s, acModeNames, nil, "AC mode")
+}
+
+// ParseFanSpeed parses "auto", "quiet", "low", "medium" ("med"), "high",
+// "powerful" or "turbo".
+func ParseFanSpeed(s string) (FanSpeed, error) {
+	return parseEnum(s, fanSpeedNames, fanSpeedAliases, "fan speed")
+}
+
+// String returns the protocol name of v.
+func (v GroupPowerCommand) String() string {
+	return enumString(v, groupPowerCommandNames, "GroupPowerCommand")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v GroupPowerCommand) Valid() bool {
+	_, ok := groupPowerCommandNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v GroupPowerCommand) MarshalText() ([]byte, error) {
+	return enumText(v, groupPowerCommandNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *GroupPowerCommand) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseGroupPowerCommand)
+}
+
+// String returns the protocol name of v.
+func (v GroupPowerState) String() string {
+	return enumString(v, groupPowerStateNames, "GroupPowerState")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v GroupPowerState) Valid() bool {
+	_, ok := groupPowerStateNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v GroupPowerState) MarshalText() ([]byte, error) {
+	return enumText(v, groupPowerStateNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *GroupPowerState) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseGroupPowerState)
+}
+
+// String returns the protocol name of v.
+func (v GroupValue) String() string {
+	return enumString(v, groupValueNames, "GroupValue")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v GroupValue) Valid() bool {
+	_, ok := groupValueNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v GroupValue) MarshalText() ([]byte, error) {
+	return enumText(v, groupValueNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *GroupValue) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseGroupValue)
+}
+
+// String returns the protocol name of v.
+func (v ACPowerCommand) String() string {
+	return enumString(v, acPowerCommandNames, "ACPowerCommand")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v ACPowerCommand) Valid() bool {
+	_, ok := acPowerCommandNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v ACPowerCommand) MarshalText() ([]byte, error) {
+	return enumText(v, acPowerCommandNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *ACPowerCommand) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseACPowerCommand)
+}
+
+// String returns the protocol name of v.
+func (v ACPowerState) String() string {
+	return enumString(v, acPowerStateNames, "ACPowerState")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v ACPowerState) Valid() bool {
+	_, ok := acPowerStateNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v ACPowerState) MarshalText() ([]byte, error) {
+	return enumText(v, acPowerStateNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *ACPowerState) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseACPowerState)
+}
+
+// String returns the protocol name of v.
+func (v ACMode) String() string {
+	return enumString(v, acModeNames, "ACMode")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v ACMode) Valid() bool {
+	_, ok := acModeNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v ACMode) MarshalText() ([]byte, error) {
+	return enumText(v, acModeNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *ACMode) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseACMode)
+}
+
+// String returns the protocol name of v.
+func (v FanSpeed) String() string {
+	return enumString(v, fanSpeedNames, "FanSpeed")
+}
+
+// Valid reports whether v is a value defined by the protocol.
+func (v FanSpeed) Valid() bool {
+	_, ok := fanSpeedNames[v]
+	return ok
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (v FanSpeed) MarshalText() ([]byte, error) {
+	return enumText(v, fanSpeedNames)
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (v *FanSpeed) UnmarshalText(b []byte) error {
+	return unmarshalEnum(v, b, ParseFanSpeed)
+}
+
+// enumNames returns the canonical names of the values of an enum type,
+// in wire order.
+func enumNames[T ~uint8](names map[T]string) []string {
+	out := make([]string, 0, len(names))
+	for v := 0; v <= 0xFF; v++ {
+		if name, ok := names[T(v)]; ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// enumString returns the name of v, or "Type(n)" for unknown values.
+func enumString[T ~uint8](v T, names map[T]string, typeName string) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("%s(%d)", typeName, uint8(v))
+}
+
+// enumText returns the name of v, or its decimal value if it is unknown so
+// that status the device reports as "not available" still serialises.
+func enumText[T ~uint8](v T, names map[T]string) ([]byte, error) {
+	if name, ok := names[v]; ok {
+		return []byte(name), nil
+	}
+	return []byte(strconv.Itoa(int(v))), nil
+}
+
+// parseEnum looks up s (case-insensitive) among the names and aliases of an
+// enum type. The decimal wire value of a known value is accepted too.
+func parseEnum[T ~uint8](s string, names map[T]string, aliases map[string]T, what string) (T, error) {
+	key := strings.ToLower(strings.TrimSpace(s))
+	for v, name := range names {
+		if name == key {
+			return v, nil
+		}
+	}
+	if v, ok := aliases[key]; ok {
+		return v, nil
+	}
+	if n, err := strconv.ParseUint(key, 10, 8); err == nil {
+		if _, ok := names[T(n)]; ok {
+			return T(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q (valid: %s)", what, s, strings.Join(enumNames(names), ", "))
+}
+
+// unmarshalEnum parses b into v. Unlike the Parse functions it also accepts
+// the decimal form enumText produces for unknown values.
+func unmarshalEnum[T ~uint8](v *T, b []byte, parse func(string) (T, error)) error {
+	parsed, err := parse(string(b))
+	if err != nil {
+		n, numErr := strconv.ParseUint(string(b), 10, 8)
+		if numErr != nil {
+			return err
+		}
+		parsed = T(n)
+	}
+	*v = parsed
+	return nil
+}
@@ -0,0 +1,115 @@
+package at2plus
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnums_String(t *testing.T) {
+	assert.Equal(t, "next", GroupPowerNext.String())
+	assert.Equal(t, "turbo", GroupPowerStateTurbo.String())
+	assert.Equal(t, "increase", GroupValueIncrease.String())
+	assert.Equal(t, "sleep", ACPowerSleep.String())
+	assert.Equal(t, "away-on", ACPowerStateAwayOn.String())
+	assert.Equal(t, "auto-cool", ACModeAutoCool.String())
+	assert.Equal(t, "powerful", FanSpeedPowerful.String())
+
+	assert.Equal(t, "ACMode(15)", ACMode(15).String())
+	assert.Equal(t, "GroupPowerCommand(0)", GroupPowerCommand(0).String())
+}
+
+func TestEnums_ControlAndStatusNumberingDiffer(t *testing.T) {
+	// Group power 1 means Next in control but On in status
+	assert.Equal(t, "next", GroupPowerCommand(1).String())
+	assert.Equal(t, "on", GroupPowerState(1).String())
+
+	// AC power 2 means Off in control but Away (Off) in status
+	assert.Equal(t, "off", ACPowerCommand(2).String())
+	assert.Equal(t, "away-off", ACPowerState(2).String())
+}
+
+func TestParseEnums(t *testing.T) {
+	mode, err := ParseACMode("Cool")
+	require.NoError(t, err)
+	assert.Equal(t, ACModeCool, mode)
+
+	fan, err := ParseFanSpeed("med")
+	require.NoError(t, err)
+	assert.Equal(t, FanSpeedMedium, fan)
+
+	value, err := ParseGroupValue("inc")
+	require.NoError(t, err)
+	assert.Equal(t, GroupValueIncrease, value)
+
+	power, err := ParseACPowerCommand("4")
+	require.NoError(t, err)
+	assert.Equal(t, ACPowerAway, power)
+
+	gp, err := ParseGroupPowerCommand(" turbo ")
+	require.NoError(t, err)
+	assert.Equal(t, GroupPowerTurbo, gp)
+}
+
+func TestParseEnums_Unknown(t *testing.T) {
+	_, err := ParseACMode("hot")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "auto, heat, dry, fan, cool, auto-heat, auto-cool")
+
+	_, err = ParseACMode("7")
+	assert.Error(t, err)
+
+	_, err = ParseFanSpeed("")
+	assert.Error(t, err)
+
+	_, err = ParseGroupPowerState("next")
+	assert.Error(t, err)
+
+	_, err = ParseACPowerState("toggle")
+	assert.Error(t, err)
+}
+
+func TestEnums_Valid(t *testing.T) {
+	assert.True(t, ACModeAutoHeat.Valid())
+	assert.False(t, ACMode(5).Valid())
+	assert.True(t, GroupPowerTurbo.Valid())
+	assert.False(t, GroupPowerCommand(4).Valid())
+	assert.False(t, FanSpeed(7).Valid())
+}
+
+func TestACPowerState_IsOn(t *testing.T) {
+	assert.True(t, ACPowerStateOn.IsOn())
+	assert.True(t, ACPowerStateAwayOn.IsOn())
+	assert.True(t, ACPowerStateSleep.IsOn())
+	assert.False(t, ACPowerStateOff.IsOn())
+	assert.False(t, ACPowerStateAwayOff.IsOn())
+}
+
+func TestEnums_JSON(t *testing.T) {
+	st := ACStatus{ACNumber: 1, Power: ACPowerStateOn, Mode: ACModeCool, FanSpeed: FanSpeedHigh}
+
+	data, err := json.Marshal(st)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"power":"on"`)
+	assert.Contains(t, string(data), `"mode":"cool"`)
+	assert.Contains(t, string(data), `"fan_speed":"high"`)
+
+	var got ACStatus
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, st, got)
+}
+
+func TestEnums_TextUnknownValueRoundTrip(t *testing.T) {
+	// The device reports values outside the spec as "not available"
+	text, err := ACMode(15).MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "15", string(text))
+
+	var mode ACMode
+	require.NoError(t, mode.UnmarshalText(text))
+	assert.Equal(t, ACMode(15), mode)
+
+	assert.Error(t, mode.UnmarshalText([]byte("hot")))
+}
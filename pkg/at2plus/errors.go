@@ -0,0 +1,35 @@
+package at2plus
+
+import "errors"
+
+// Sentinel errors returned by Client methods. Errors are wrapped with
+// context, so test for them with errors.Is.
+var (
+	// ErrClosed is returned for requests made on, or interrupted by, a
+	// closed client.
+	ErrClosed = errors.New("client closed")
+	// ErrConnectionLost is returned for requests that could not complete
+	// because the connection to the device dropped. It wraps the read or
+	// write error that caused it.
+	ErrConnectionLost = errors.New("connection lost")
+	// ErrTimeout is returned when the device does not answer a request
+	// within the request timeout or the context deadline.
+	ErrTimeout = errors.New("request timed out")
+	// ErrProtocol matches every error caused by a malformed or unexpected
+	// message from the device, such as ErrInvalidChecksum.
+	ErrProtocol = errors.New("protocol error")
+)
+
+// protocolError is an error in a message received from the device.
+// It matches ErrProtocol.
+type protocolError string
+
+// Error implements the error interface.
+func (e protocolError) Error() string {
+	return string(e)
+}
+
+// Is reports whether target is ErrProtocol.
+func (e protocolError) Is(target error) bool {
+	return target == ErrProtocol
+}
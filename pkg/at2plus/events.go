@@ -0,0 +1,114 @@
+package at2plus
+
+import "time"
+
+// Event is a change in the state kept by a StateStore. The concrete types
+// are the *Changed, ACErrorRaised and ACErrorCleared structs below; use a
+// type switch to handle the ones of interest:
+//
+//	for ev := range store.Watch(ctx) {
+//	    switch ev := ev.(type) {
+//	    case at2plus.GroupPowerChanged:
+//	        fmt.Printf("group %d turned %s\n", ev.Group, ev.New)
+//	    case at2plus.ACErrorRaised:
+//	        fmt.Printf("AC %d: %s\n", ev.AC, ev.Message)
+//	    }
+//	}
+type Event interface {
+	// When returns the time the change was observed.
+	When() time.Time
+}
+
+// EventTime is embedded in every Event and records when it was observed.
+type EventTime struct {
+	Time time.Time
+}
+
+// When implements Event.
+func (t EventTime) When() time.Time {
+	return t.Time
+}
+
+// GroupPowerChanged is emitted when a group turns on, off or to turbo.
+type GroupPowerChanged struct {
+	EventTime
+	Group    uint8
+	Old, New GroupPowerState
+}
+
+// GroupPercentChanged is emitted when a group's damper opening changes.
+type GroupPercentChanged struct {
+	EventTime
+	Group    uint8
+	Old, New int
+}
+
+// GroupSpillChanged is emitted when a group starts or stops taking spill
+// air.
+type GroupSpillChanged struct {
+	EventTime
+	Group    uint8
+	Old, New bool
+}
+
+// GroupNameChanged is emitted when a group is renamed on the console.
+type GroupNameChanged struct {
+	EventTime
+	Group    uint8
+	Old, New string
+}
+
+// ACPowerChanged is emitted when an AC's power state changes.
+type ACPowerChanged struct {
+	EventTime
+	AC       uint8
+	Old, New ACPowerState
+}
+
+// ACModeChanged is emitted when an AC's mode changes.
+type ACModeChanged struct {
+	EventTime
+	AC       uint8
+	Old, New ACMode
+}
+
+// ACFanSpeedChanged is emitted when an AC's fan speed changes.
+type ACFanSpeedChanged struct {
+	EventTime
+	AC       uint8
+	Old, New FanSpeed
+}
+
+// ACSetpointChanged is emitted when an AC's setpoint changes.
+type ACSetpointChanged struct {
+	EventTime
+	AC       uint8
+	Old, New Temperature
+}
+
+// ACTemperatureChanged is emitted when the temperature an AC measures
+// changes.
+type ACTemperatureChanged struct {
+	EventTime
+	AC       uint8
+	Old, New Temperature
+}
+
+// ACErrorRaised is emitted when an AC reports an error code, or a
+// different one than before.
+type ACErrorRaised struct {
+	EventTime
+	AC   uint8
+	Code int
+	// Message is the error information from GetACError, if it could be
+	// fetched.
+	Message string
+}
+
+// ACErrorCleared is emitted when an AC no longer reports an error.
+type ACErrorCleared struct {
+	EventTime
+	AC uint8
+	// Code is the error code the AC reported before.
+	Code int
+}
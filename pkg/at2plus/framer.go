@@ -0,0 +1,133 @@
+package at2plus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// headerLen is the length of the fixed packet header:
+// Header(2) + Address(2) + ID(1) + Type(1) + Length(2).
+const headerLen = 8
+
+// FramerStats are counters of data a Framer could not frame.
+type FramerStats struct {
+	// DroppedBytes counts bytes skipped while searching for a valid packet.
+	DroppedBytes uint64
+	// BadChecksums counts candidate packets rejected because of their CRC.
+	BadChecksums uint64
+}
+
+// Framer splits a byte stream into packets. It scans byte by byte for the
+// 0x55 0x55 header, checks the length and CRC, and on any mismatch resumes
+// the search one byte after the rejected header, so stray or corrupted
+// bytes never leave the stream misaligned.
+//
+// A Framer works on any io.Reader, e.g. a live connection or a captured
+// stream read from a file. It is not safe for concurrent use, except for
+// Stats.
+type Framer struct {
+	r            *bufio.Reader
+	droppedBytes atomic.Uint64
+	badChecksums atomic.Uint64
+}
+
+// NewFramer returns a Framer reading from r.
+func NewFramer(r io.Reader) *Framer {
+	return &Framer{r: bufio.NewReaderSize(r, headerLen+MaxDataLen+2)}
+}
+
+// Reset discards any buffered data and switches to reading from r.
+// The counters are kept.
+func (f *Framer) Reset(r io.Reader) {
+	f.r.Reset(r)
+}
+
+// Stats returns a snapshot of the framer's counters.
+func (f *Framer) Stats() FramerStats {
+	return FramerStats{
+		DroppedBytes: f.droppedBytes.Load(),
+		BadChecksums: f.badChecksums.Load(),
+	}
+}
+
+// Next returns the next valid packet. At the end of the stream it returns
+// io.EOF, or io.ErrUnexpectedEOF if the stream ends inside a packet.
+// Other read errors are returned as is.
+func (f *Framer) Next() (*Packet, error) {
+	p := &Packet{}
+	if err := f.NextInto(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NextInto is like Next but decodes into p, reusing the capacity of
+// p.Data. Reading a stream into the same Packet does not allocate.
+func (f *Framer) NextInto(p *Packet) error {
+	for {
+		if err := f.seekHeader(); err != nil {
+			return err
+		}
+
+		header, err := f.r.Peek(headerLen)
+		if err != nil {
+			return f.eof(err)
+		}
+		dataLen := int(binary.BigEndian.Uint16(header[6:8]))
+		if dataLen > MaxDataLen {
+			// Not a real header
+			f.skip(1)
+			continue
+		}
+
+		frame, err := f.r.Peek(headerLen + dataLen + 2)
+		if err != nil {
+			return f.eof(err)
+		}
+		if err := DecodeInto(p, frame); err != nil {
+			if errors.Is(err, ErrInvalidChecksum) {
+				f.badChecksums.Add(1)
+			}
+			f.skip(1)
+			continue
+		}
+
+		f.r.Discard(len(frame))
+		return nil
+	}
+}
+
+// seekHeader skips bytes until the buffer starts with the header magic.
+func (f *Framer) seekHeader() error {
+	for {
+		b, err := f.r.Peek(2)
+		if err != nil {
+			if len(b) > 0 && b[0] != 0x55 {
+				f.skip(len(b))
+			}
+			return f.eof(err)
+		}
+		if b[0] == 0x55 && b[1] == 0x55 {
+			return nil
+		}
+		f.skip(1)
+	}
+}
+
+// skip drops n buffered bytes.
+func (f *Framer) skip(n int) {
+	n, _ = f.r.Discard(n)
+	f.droppedBytes.Add(uint64(n))
+}
+
+// eof maps an end of stream in the middle of a packet to
+// io.ErrUnexpectedEOF.
+func (f *Framer) eof(err error) error {
+	if err == io.EOF && f.r.Buffered() > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package at2plus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// specGroupStatusPacket is the group status response from the protocol spec.
+var specGroupStatusPacket, _ = hex.DecodeString("5555B08001C00018210000000002000800000000000080004132000000000200832F")
+
+func framerStream(parts ...[]byte) *Framer {
+	return NewFramer(bytes.NewReader(bytes.Join(parts, nil)))
+}
+
+func TestFramer_Stream(t *testing.T) {
+	second := NewPacket(AddressRecvExtended, 7, MsgTypeExtended, []byte{0xFF, 0x10, 0x00, 0x00}).Encode()
+	f := framerStream(specGroupStatusPacket, second)
+
+	p, err := f.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), p.MsgID)
+	assert.Equal(t, specGroupStatusData, p.Data)
+
+	p, err = f.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(7), p.MsgID)
+
+	_, err = f.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, FramerStats{}, f.Stats())
+}
+
+func TestFramer_ResyncAfterStrayBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		garbage []byte
+	}{
+		{"single byte", []byte{0x00}},
+		{"lone magic byte", []byte{0x55}},
+		{"odd garbage", []byte{0x01, 0x02, 0x03}},
+		{"false header", []byte{0x55, 0x55, 0xFF, 0xFF, 0x00, 0x00, 0xFF, 0xFF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := framerStream(tt.garbage, specGroupStatusPacket)
+
+			p, err := f.Next()
+			require.NoError(t, err)
+			assert.Equal(t, specGroupStatusData, p.Data)
+			assert.Equal(t, uint64(len(tt.garbage)), f.Stats().DroppedBytes)
+		})
+	}
+}
+
+func TestFramer_ResyncAfterBadChecksum(t *testing.T) {
+	corrupt := bytes.Clone(specGroupStatusPacket)
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	f := framerStream(corrupt, specGroupStatusPacket)
+
+	p, err := f.Next()
+	require.NoError(t, err)
+	assert.Equal(t, specGroupStatusData, p.Data)
+	assert.Equal(t, FramerStats{DroppedBytes: uint64(len(corrupt)), BadChecksums: 1}, f.Stats())
+}
+
+func TestFramer_TruncatedPacket(t *testing.T) {
+	f := framerStream(specGroupStatusPacket[:20])
+
+	_, err := f.Next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestFramer_Reset(t *testing.T) {
+	f := framerStream([]byte{0x00}, specGroupStatusPacket[:20])
+	_, err := f.Next()
+	require.Error(t, err)
+
+	f.Reset(bytes.NewReader(specGroupStatusPacket))
+	p, err := f.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), p.MsgID)
+	assert.Equal(t, uint64(1), f.Stats().DroppedBytes, "counters survive a reset")
+}
+
+// repeatReader yields the same bytes forever.
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		c := copy(b[n:], r.data[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.data)
+	}
+	return n, nil
+}
+
+func BenchmarkFramer_NextInto(b *testing.B) {
+	f := NewFramer(&repeatReader{data: specGroupStatusPacket})
+	p := &Packet{}
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := f.NextInto(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,47 +4,49 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // GroupControl represents a command to control a group
 type GroupControl struct {
-	GroupNumber uint8 // 0-15
-	Power       *int  // 0: Next, 1: Off, 2: On, 3: Turbo (Mapped from spec: 001->Next, 010->Off, 011->On, 101->Turbo)
-	Value       *int  // 0: Dec, 1: Inc, 2: Set (Mapped from spec: 010->Dec, 011->Inc, 100->Set)
-	Percent     *int  // 0-100
+	GroupNumber uint8              // 0-15
+	Power       *GroupPowerCommand // nil: Keep
+	Value       *GroupValue        // nil: Keep
+	Percent     *int               // 0-100, implies Set if Value is nil
 }
 
 // GroupStatus represents the status of a group
 type GroupStatus struct {
-	GroupNumber  uint8
-	Power        int // 0: Off, 1: On, 3: Turbo
-	Percent      int
-	TurboSupport bool
-	Spill        bool
+	GroupNumber  uint8           `json:"group"`
+	Power        GroupPowerState `json:"power"`
+	Percent      int             `json:"percent"`
+	TurboSupport bool            `json:"turbo_support"`
+	Spill        bool            `json:"spill"`
 }
 
 // ACControl represents a command to control an AC
 type ACControl struct {
-	ACNumber uint8 // 0-7
-	Power    *int  // 1: Toggle, 2: Off, 3: On, 4: Away, 5: Sleep
-	Mode     *int  // 0: Auto, 1: Heat, 2: Dry, 3: Fan, 4: Cool
-	FanSpeed *int  // 0: Auto, 1: Quiet, 2: Low, 3: Med, 4: High, 5: Powerful, 6: Turbo
-	Setpoint *int  // 10-35
+	ACNumber uint8           // 0-7
+	Power    *ACPowerCommand // nil: Keep
+	Mode     *ACMode         // ACModeAuto-ACModeCool, nil: Keep
+	FanSpeed *FanSpeed       // nil: Keep
+	Setpoint *Temperature    // 10.0-35.0°C, nil: Keep
 }
 
 // ACStatus represents the status of an AC
 type ACStatus struct {
-	ACNumber    uint8
-	Power       int // 0: Off, 1: On, ...
-	Mode        int
-	FanSpeed    int
-	Setpoint    int
-	Temperature int
-	Turbo       bool
-	Bypass      bool
-	Spill       bool
-	Timer       bool
-	ErrorCode   int
+	ACNumber    uint8        `json:"ac"`
+	Power       ACPowerState `json:"power"`
+	Mode        ACMode       `json:"mode"`
+	FanSpeed    FanSpeed     `json:"fan_speed"`
+	Setpoint    Temperature  `json:"setpoint"`
+	Temperature Temperature  `json:"temperature"`
+	Turbo       bool         `json:"turbo"`
+	Bypass      bool         `json:"bypass"`
+	Spill       bool         `json:"spill"`
+	Timer       bool         `json:"timer"`
+	ErrorCode   int          `json:"error_code"` // 0: No error, see GetACError for details
 }
 
 // MarshalGroupControl creates the byte payload for a Group Control message
@@ -77,39 +79,28 @@ func MarshalGroupControl(groups []GroupControl) ([]byte, error) {
 
 		// Byte 2: Bit8-6 Group Setting Value, Bit3-1 Power
 		var b2 uint8
+		if g.Value == nil && g.Percent != nil {
+			b2 |= uint8(GroupValueSet) << 5 // A percentage only takes effect with Set
+		}
 		if g.Value != nil {
-			// Map: 0->010(2), 1->011(3), 2->100(4)
-			val := 0
-			switch *g.Value {
-			case 0:
-				val = 2 // Dec
-			case 1:
-				val = 3 // Inc
-			case 2:
-				val = 4 // Set
+			if !g.Value.Valid() {
+				return nil, fmt.Errorf("group %d: invalid value %d", g.GroupNumber, uint8(*g.Value))
 			}
-			b2 |= uint8(val << 5)
+			b2 |= uint8(*g.Value) << 5
 		}
 		if g.Power != nil {
-			// Map: 0->001(1), 1->010(2), 2->011(3), 3->101(5)
-			val := 0
-			switch *g.Power {
-			case 0:
-				val = 1 // Next
-			case 1:
-				val = 2 // Off
-			case 2:
-				val = 3 // On
-			case 3:
-				val = 5 // Turbo
+			if !g.Power.Valid() {
+				return nil, fmt.Errorf("group %d: invalid power %d", g.GroupNumber, uint8(*g.Power))
 			}
-			b2 |= uint8(val)
+			b2 |= uint8(*g.Power)
 		}
 		buf[offset+1] = b2
 
-		// Byte 3: Percentage
+		// Byte 3: Percentage (Other: Keep)
 		if g.Percent != nil {
 			buf[offset+2] = uint8(*g.Percent)
+		} else if g.Value != nil && *g.Value == GroupValueSet {
+			buf[offset+2] = 0xFF
 		}
 
 		// Byte 4: 0
@@ -117,23 +108,83 @@ func MarshalGroupControl(groups []GroupControl) ([]byte, error) {
 	return buf, nil
 }
 
-// UnmarshalGroupStatus parses the byte payload of a Group Status message
-func UnmarshalGroupStatus(data []byte) ([]GroupStatus, error) {
-	if len(data) < 8 {
-		return nil, ErrInvalidLength
+// UnmarshalGroupControl parses the byte payload of a Group Control message.
+// Fields the message leaves unchanged are returned as nil. Percent is only
+// set when Value is Set, as the device ignores it otherwise.
+func UnmarshalGroupControl(data []byte) ([]GroupControl, error) {
+	count, repeatLen, err := parseSubHeader(data, SubMsgTypeGroupControl, 4)
+	if err != nil {
+		return nil, fmt.Errorf("group control: %w", err)
 	}
 
-	subType := data[0]
-	if subType != SubMsgTypeGroupStatus {
-		return nil, fmt.Errorf("invalid sub type for group status: %x", subType)
+	groups := make([]GroupControl, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := data[8+i*repeatLen:]
+
+		g := GroupControl{GroupNumber: chunk[0] & 0x3F}
+
+		// Byte 2: Bit8-6 Group Setting Value (other: keep)
+		if value := GroupValue(chunk[1] >> 5); value.Valid() {
+			g.Value = &value
+		}
+
+		// Byte 2: Bit3-1 Power (other: keep)
+		if power := GroupPowerCommand(chunk[1] & 0x07); power.Valid() {
+			g.Power = &power
+		}
+
+		// Byte 3: Percentage, only meaningful with Set
+		if g.Value != nil && *g.Value == GroupValueSet && chunk[2] <= 100 {
+			percent := int(chunk[2])
+			g.Percent = &percent
+		}
+
+		groups = append(groups, g)
 	}
+	return groups, nil
+}
 
-	count := int(binary.BigEndian.Uint16(data[4:6]))
-	repeatLen := int(binary.BigEndian.Uint16(data[6:8]))
+// MarshalGroupStatus creates the byte payload of a Group Status message,
+// as sent by the device.
+func MarshalGroupStatus(groups []GroupStatus) ([]byte, error) {
+	buf := make([]byte, 8+len(groups)*8)
+	putSubHeader(buf, SubMsgTypeGroupStatus, len(groups), 8)
 
-	expectedLen := 8 + count*repeatLen
-	if len(data) < expectedLen {
-		return nil, ErrInvalidLength
+	for i, g := range groups {
+		chunk := buf[8+i*8 : 8+(i+1)*8]
+
+		if g.GroupNumber > 15 {
+			return nil, fmt.Errorf("group %d: invalid group number", g.GroupNumber)
+		}
+		if g.Power > 3 {
+			return nil, fmt.Errorf("group %d: invalid power %d", g.GroupNumber, uint8(g.Power))
+		}
+		if g.Percent < 0 || g.Percent > 100 {
+			return nil, fmt.Errorf("group %d: invalid percent %d", g.GroupNumber, g.Percent)
+		}
+
+		// Byte 1: Bit8-7 Power, Bit6-1 Group Num
+		chunk[0] = uint8(g.Power)<<6 | g.GroupNumber
+
+		// Byte 2: Bit7-1 Open Percentage
+		chunk[1] = uint8(g.Percent)
+
+		// Byte 7: Bit8 Turbo Support, Bit2 Spill
+		if g.TurboSupport {
+			chunk[6] |= 0x80
+		}
+		if g.Spill {
+			chunk[6] |= 0x02
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalGroupStatus parses the byte payload of a Group Status message
+func UnmarshalGroupStatus(data []byte) ([]GroupStatus, error) {
+	count, repeatLen, err := parseSubHeader(data, SubMsgTypeGroupStatus, 8)
+	if err != nil {
+		return nil, fmt.Errorf("group status: %w", err)
 	}
 
 	groups := make([]GroupStatus, 0, count)
@@ -154,7 +205,7 @@ func UnmarshalGroupStatus(data []byte) ([]GroupStatus, error) {
 
 		groups = append(groups, GroupStatus{
 			GroupNumber:  groupNum,
-			Power:        int(power),
+			Power:        GroupPowerState(power),
 			Percent:      int(percent),
 			TurboSupport: turboSupport,
 			Spill:        spill,
@@ -178,72 +229,178 @@ func MarshalACControl(acs []ACControl) ([]byte, error) {
 		// Byte 1: Bit8-5 Power, Bit4-1 AC Number
 		var b1 uint8
 		if ac.Power != nil {
-			// Map: 1->0001, 2->0010, 3->0011, 4->0100, 5->0101
-			// Spec: 1:Change, 2:Off, 3:On, 4:Away, 5:Sleep
-			// Wait, my struct comments mapped 1:Toggle, 2:Off, 3:On...
-			// Spec: 0001: Change on/off, 0010: Off, 0011: On, 0100: Away, 0101: Sleep
-			val := 0
-			switch *ac.Power {
-			case 1:
-				val = 1
-			case 2:
-				val = 2
-			case 3:
-				val = 3
-			case 4:
-				val = 4
-			case 5:
-				val = 5
+			if !ac.Power.Valid() {
+				return nil, fmt.Errorf("ac %d: invalid power %d", ac.ACNumber, uint8(*ac.Power))
 			}
-			b1 |= uint8(val << 4)
+			b1 |= uint8(*ac.Power) << 4
 		}
 		b1 |= ac.ACNumber & 0x0F
 		buf[offset] = b1
 
-		// Byte 2: Bit8-5 Mode, Bit4-1 Fan Speed
-		var b2 uint8
+		// Byte 2: Bit8-5 Mode, Bit4-1 Fan Speed (0xF: Keep)
+		b2 := uint8(0xFF)
 		if ac.Mode != nil {
-			b2 |= uint8(*ac.Mode << 4)
+			if *ac.Mode > ACModeCool {
+				return nil, fmt.Errorf("ac %d: invalid mode %s for control", ac.ACNumber, *ac.Mode)
+			}
+			b2 = b2&0x0F | uint8(*ac.Mode)<<4
 		}
 		if ac.FanSpeed != nil {
-			b2 |= uint8(*ac.FanSpeed)
+			if !ac.FanSpeed.Valid() {
+				return nil, fmt.Errorf("ac %d: invalid fan speed %d", ac.ACNumber, uint8(*ac.FanSpeed))
+			}
+			b2 = b2&0xF0 | uint8(*ac.FanSpeed)
 		}
 		buf[offset+1] = b2
 
 		// Byte 3: Setpoint Control
 		// Byte 4: Setpoint Value
 		if ac.Setpoint != nil {
-			buf[offset+2] = 0x40 // Change setpoint
-			// Setpoint = (data+100)/10 -> data = Setpoint*10 - 100
-			val := (*ac.Setpoint * 10) - 100
-			if val < 0 {
-				val = 0
+			if *ac.Setpoint < MinSetpoint || *ac.Setpoint > MaxSetpoint {
+				return nil, fmt.Errorf("ac %d: setpoint %s out of range", ac.ACNumber, *ac.Setpoint)
 			}
-			buf[offset+3] = uint8(val)
+			buf[offset+2] = 0x40 // Change setpoint
+			// Setpoint = (data+100)/10 -> data = Setpoint*10 - 100, in tenths: data = tenths - 100
+			buf[offset+3] = uint8(*ac.Setpoint - 100)
 		} else {
 			buf[offset+2] = 0x00 // Keep setpoint
+			buf[offset+3] = 0xFF
 		}
 	}
 	return buf, nil
 }
 
-// UnmarshalACStatus parses the byte payload of an AC Status message
-func UnmarshalACStatus(data []byte) ([]ACStatus, error) {
+// UnmarshalACControl parses the byte payload of an AC Control message.
+// Fields the message leaves unchanged are returned as nil.
+func UnmarshalACControl(data []byte) ([]ACControl, error) {
+	count, repeatLen, err := parseSubHeader(data, SubMsgTypeACControl, 4)
+	if err != nil {
+		return nil, fmt.Errorf("ac control: %w", err)
+	}
+
+	acs := make([]ACControl, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := data[8+i*repeatLen:]
+
+		ac := ACControl{ACNumber: chunk[0] & 0x0F}
+
+		// Byte 1: Bit8-5 Power (other: keep)
+		if power := ACPowerCommand(chunk[0] >> 4); power.Valid() {
+			ac.Power = &power
+		}
+
+		// Byte 2: Bit8-5 Mode (auto-cool), Bit4-1 Fan Speed, other: keep
+		if mode := ACMode(chunk[1] >> 4); mode <= ACModeCool {
+			ac.Mode = &mode
+		}
+		if fan := FanSpeed(chunk[1] & 0x0F); fan.Valid() {
+			ac.FanSpeed = &fan
+		}
+
+		// Byte 3: 0x40 change setpoint, Byte 4: (data+100)/10
+		if chunk[2] == 0x40 {
+			setpoint := Temperature(int(chunk[3]) + 100)
+			ac.Setpoint = &setpoint
+		}
+
+		acs = append(acs, ac)
+	}
+	return acs, nil
+}
+
+// parseSubHeader validates the 8-byte sub header of a 0xC0 message and
+// returns the repeat count and repeat length. Repeat data shorter than
+// minRepeatLen is rejected.
+func parseSubHeader(data []byte, subType uint8, minRepeatLen int) (count, repeatLen int, err error) {
 	if len(data) < 8 {
-		return nil, ErrInvalidLength
+		return 0, 0, ErrInvalidLength
+	}
+	if data[0] != subType {
+		return 0, 0, fmt.Errorf("%w: %x", ErrInvalidSubType, data[0])
 	}
 
-	subType := data[0]
-	if subType != SubMsgTypeACStatus {
-		return nil, fmt.Errorf("invalid sub type for ac status: %x", subType)
+	count = int(binary.BigEndian.Uint16(data[4:6]))
+	repeatLen = int(binary.BigEndian.Uint16(data[6:8]))
+	if count > 0 && repeatLen < minRepeatLen {
+		return 0, 0, ErrInvalidLength
+	}
+	if len(data) < 8+count*repeatLen {
+		return 0, 0, ErrInvalidLength
 	}
+	return count, repeatLen, nil
+}
 
-	count := int(binary.BigEndian.Uint16(data[4:6]))
-	repeatLen := int(binary.BigEndian.Uint16(data[6:8]))
+// putSubHeader writes the 8-byte sub header of a 0xC0 message.
+func putSubHeader(buf []byte, subType uint8, count, repeatLen int) {
+	buf[0] = subType
+	binary.BigEndian.PutUint16(buf[4:6], uint16(count))
+	binary.BigEndian.PutUint16(buf[6:8], uint16(repeatLen))
+}
 
-	expectedLen := 8 + count*repeatLen
-	if len(data) < expectedLen {
-		return nil, ErrInvalidLength
+// MarshalACStatus creates the byte payload of an AC Status message,
+// as sent by the device.
+func MarshalACStatus(acs []ACStatus) ([]byte, error) {
+	buf := make([]byte, 8+len(acs)*10)
+	putSubHeader(buf, SubMsgTypeACStatus, len(acs), 10)
+
+	for i, ac := range acs {
+		chunk := buf[8+i*10 : 8+(i+1)*10]
+
+		if ac.ACNumber > 15 || ac.Power > 15 {
+			return nil, fmt.Errorf("ac %d: invalid ac number or power", ac.ACNumber)
+		}
+		if ac.Mode > 15 || ac.FanSpeed > 15 {
+			return nil, fmt.Errorf("ac %d: invalid mode or fan speed", ac.ACNumber)
+		}
+
+		// Byte 1: Bit8-5 Power, Bit4-1 AC Num
+		chunk[0] = uint8(ac.Power)<<4 | ac.ACNumber
+
+		// Byte 2: Bit8-5 Mode, Bit4-1 Fan
+		chunk[1] = uint8(ac.Mode)<<4 | uint8(ac.FanSpeed)
+
+		// Byte 3: Setpoint (VALUE+100)/10, VALUE 0-250
+		setpointVal := int(ac.Setpoint) - 100
+		if setpointVal < 0 || setpointVal > 250 {
+			return nil, fmt.Errorf("ac %d: setpoint %s out of range", ac.ACNumber, ac.Setpoint)
+		}
+		chunk[2] = uint8(setpointVal)
+
+		// Byte 4: Turbo, Bypass, Spill, Timer
+		if ac.Turbo {
+			chunk[3] |= 0x10
+		}
+		if ac.Bypass {
+			chunk[3] |= 0x08
+		}
+		if ac.Spill {
+			chunk[3] |= 0x04
+		}
+		if ac.Timer {
+			chunk[3] |= 0x02
+		}
+
+		// Byte 5-6: Temperature (VALUE-500)/10, VALUE 0-2000
+		tempVal := int(ac.Temperature) + 500
+		if tempVal < 0 || tempVal > 2000 {
+			return nil, fmt.Errorf("ac %d: temperature %s out of range", ac.ACNumber, ac.Temperature)
+		}
+		binary.BigEndian.PutUint16(chunk[4:6], uint16(tempVal))
+
+		// Byte 7-8: Error Code
+		if ac.ErrorCode < 0 || ac.ErrorCode > 0xFFFF {
+			return nil, fmt.Errorf("ac %d: invalid error code %d", ac.ACNumber, ac.ErrorCode)
+		}
+		binary.BigEndian.PutUint16(chunk[6:8], uint16(ac.ErrorCode))
+	}
+	return buf, nil
+}
+
+// UnmarshalACStatus parses the byte payload of an AC Status message
+func UnmarshalACStatus(data []byte) ([]ACStatus, error) {
+	count, repeatLen, err := parseSubHeader(data, SubMsgTypeACStatus, 8)
+	if err != nil {
+		return nil, fmt.Errorf("ac status: %w", err)
 	}
 
 	acs := make([]ACStatus, 0, count)
@@ -259,9 +416,8 @@ func UnmarshalACStatus(data []byte) ([]ACStatus, error) {
 		mode := (chunk[1] >> 4) & 0x0F
 		fan := chunk[1] & 0x0F
 
-		// Byte 3: Setpoint (VALUE+100)/10
-		setpointVal := int(chunk[2])
-		setpoint := (setpointVal + 100) / 10
+		// Byte 3: Setpoint (VALUE+100)/10, kept in tenths
+		setpoint := Temperature(int(chunk[2]) + 100)
 
 		// Byte 4: Turbo, Bypass, Spill, Timer
 		turbo := (chunk[3] & 0x10) != 0
@@ -269,18 +425,17 @@ func UnmarshalACStatus(data []byte) ([]ACStatus, error) {
 		spill := (chunk[3] & 0x04) != 0
 		timer := (chunk[3] & 0x02) != 0
 
-		// Byte 5-6: Temperature (VALUE-500)/10
-		tempVal := int(binary.BigEndian.Uint16(chunk[4:6]))
-		temperature := (tempVal - 500) / 10
+		// Byte 5-6: Temperature (VALUE-500)/10, kept in tenths
+		temperature := Temperature(int(binary.BigEndian.Uint16(chunk[4:6])) - 500)
 
-		// Byte 7: Error Code
-		errCode := int(chunk[6])
+		// Byte 7-8: Error Code
+		errCode := int(binary.BigEndian.Uint16(chunk[6:8]))
 
 		acs = append(acs, ACStatus{
 			ACNumber:    acNum,
-			Power:       int(power),
-			Mode:        int(mode),
-			FanSpeed:    int(fan),
+			Power:       ACPowerState(power),
+			Mode:        ACMode(mode),
+			FanSpeed:    FanSpeed(fan),
 			Setpoint:    setpoint,
 			Temperature: temperature,
 			Turbo:       turbo,
@@ -298,26 +453,26 @@ func UnmarshalACStatus(data []byte) ([]ACStatus, error) {
 
 // ACAbility represents the capabilities of an AC
 type ACAbility struct {
-	ACNumber    uint8
-	Name        string
-	StartGroup  uint8
-	GroupCount  uint8
-	CoolMode    bool
-	FanMode     bool
-	DryMode     bool
-	HeatMode    bool
-	AutoMode    bool
-	FanTurbo    bool
-	FanPowerful bool
-	FanHigh     bool
-	FanMed      bool
-	FanLow      bool
-	FanQuiet    bool
-	FanAuto     bool
-	MinCoolSet  int
-	MaxCoolSet  int
-	MinHeatSet  int
-	MaxHeatSet  int
+	ACNumber    uint8  `json:"ac"`
+	Name        string `json:"name"`
+	StartGroup  uint8  `json:"start_group"`
+	GroupCount  uint8  `json:"group_count"`
+	CoolMode    bool   `json:"cool_mode"`
+	FanMode     bool   `json:"fan_mode"`
+	DryMode     bool   `json:"dry_mode"`
+	HeatMode    bool   `json:"heat_mode"`
+	AutoMode    bool   `json:"auto_mode"`
+	FanTurbo    bool   `json:"fan_turbo"`
+	FanPowerful bool   `json:"fan_powerful"`
+	FanHigh     bool   `json:"fan_high"`
+	FanMed      bool   `json:"fan_med"`
+	FanLow      bool   `json:"fan_low"`
+	FanQuiet    bool   `json:"fan_quiet"`
+	FanAuto     bool   `json:"fan_auto"`
+	MinCoolSet  int    `json:"min_cool_set"`
+	MaxCoolSet  int    `json:"max_cool_set"`
+	MinHeatSet  int    `json:"min_heat_set"`
+	MaxHeatSet  int    `json:"max_heat_set"`
 }
 
 // UnmarshalACAbility parses the AC Ability extended message
@@ -328,7 +483,7 @@ func UnmarshalACAbility(data []byte) ([]ACAbility, error) {
 	}
 
 	if data[0] != 0xFF || data[1] != ExtMsgTypeACAbility {
-		return nil, errors.New("invalid ac ability header")
+		return nil, fmt.Errorf("%w for ac ability", ErrInvalidHeader)
 	}
 
 	// Spec says: "If there are more than one AC, the data will be repeated"
@@ -361,7 +516,7 @@ func UnmarshalACAbility(data []byte) ([]ACAbility, error) {
 
 		if offset == 0 {
 			if data[0] != 0xFF || data[1] != 0x11 {
-				return nil, errors.New("invalid header")
+				return nil, ErrInvalidHeader
 			}
 			offset += 2
 		}
@@ -373,7 +528,8 @@ func UnmarshalACAbility(data []byte) ([]ACAbility, error) {
 		acNum := data[offset]
 		length := int(data[offset+1])
 
-		if offset+2+length > len(data) {
+		// The ability fields take 24 bytes; newer firmware may append more
+		if length < 24 || offset+2+length > len(data) {
 			return nil, ErrInvalidLength
 		}
 
@@ -446,10 +602,60 @@ func UnmarshalACAbility(data []byte) ([]ACAbility, error) {
 	return abilities, nil
 }
 
+// MarshalACAbility creates the AC Ability extended message payload,
+// as sent by the device.
+func MarshalACAbility(abilities []ACAbility) ([]byte, error) {
+	buf := make([]byte, 2, 2+len(abilities)*26)
+	buf[0] = 0xFF
+	buf[1] = ExtMsgTypeACAbility
+
+	for _, a := range abilities {
+		if len(a.Name) > 16 {
+			return nil, fmt.Errorf("ac %d: name %q longer than 16 bytes", a.ACNumber, a.Name)
+		}
+		for _, v := range []int{a.MinCoolSet, a.MaxCoolSet, a.MinHeatSet, a.MaxHeatSet} {
+			if v < 0 || v > 255 {
+				return nil, fmt.Errorf("ac %d: invalid setpoint limit %d", a.ACNumber, v)
+			}
+		}
+
+		chunk := make([]byte, 26)
+		chunk[0] = a.ACNumber
+		chunk[1] = 24
+		copy(chunk[2:18], a.Name)
+		chunk[18] = a.StartGroup
+		chunk[19] = a.GroupCount
+
+		// Byte 23: Modes
+		chunk[20] = flag(a.CoolMode, 0x20) | flag(a.FanMode, 0x10) | flag(a.DryMode, 0x08) |
+			flag(a.HeatMode, 0x04) | flag(a.AutoMode, 0x02)
+
+		// Byte 24: Fan Speeds
+		chunk[21] = flag(a.FanTurbo, 0x80) | flag(a.FanPowerful, 0x40) | flag(a.FanHigh, 0x20) |
+			flag(a.FanMed, 0x10) | flag(a.FanLow, 0x08) | flag(a.FanQuiet, 0x04) | flag(a.FanAuto, 0x02)
+
+		chunk[22] = uint8(a.MinCoolSet)
+		chunk[23] = uint8(a.MaxCoolSet)
+		chunk[24] = uint8(a.MinHeatSet)
+		chunk[25] = uint8(a.MaxHeatSet)
+
+		buf = append(buf, chunk...)
+	}
+	return buf, nil
+}
+
+// flag returns mask if set, otherwise 0.
+func flag(set bool, mask uint8) uint8 {
+	if set {
+		return mask
+	}
+	return 0
+}
+
 // GroupName represents a group name
 type GroupName struct {
-	GroupNumber uint8
-	Name        string
+	GroupNumber uint8  `json:"group"`
+	Name        string `json:"name"`
 }
 
 // UnmarshalGroupName parses the Group Name extended message
@@ -459,7 +665,7 @@ func UnmarshalGroupName(data []byte) ([]GroupName, error) {
 		return nil, ErrInvalidLength
 	}
 	if data[0] != 0xFF || data[1] != ExtMsgTypeGroupName {
-		return nil, errors.New("invalid group name header")
+		return nil, fmt.Errorf("%w for group name", ErrInvalidHeader)
 	}
 
 	var names []GroupName
@@ -496,3 +702,76 @@ func UnmarshalGroupName(data []byte) ([]GroupName, error) {
 
 	return names, nil
 }
+
+// MarshalGroupName creates the Group Name extended message payload,
+// as sent by the device.
+func MarshalGroupName(names []GroupName) ([]byte, error) {
+	buf := make([]byte, 2, 2+len(names)*9)
+	buf[0] = 0xFF
+	buf[1] = ExtMsgTypeGroupName
+
+	for _, n := range names {
+		if len(n.Name) > 8 {
+			return nil, fmt.Errorf("group %d: name %q longer than 8 bytes", n.GroupNumber, n.Name)
+		}
+		chunk := make([]byte, 9)
+		chunk[0] = n.GroupNumber
+		copy(chunk[1:], n.Name)
+		buf = append(buf, chunk...)
+	}
+	return buf, nil
+}
+
+// ACError represents the error information of an AC
+type ACError struct {
+	ACNumber uint8  `json:"ac"`
+	Message  string `json:"message"` // Error info as reported, e.g. "ER: FFFE". Empty if no error.
+	Code     int    `json:"code"`    // Numeric code parsed from Message, 0 if none
+}
+
+// UnmarshalACError parses the AC Error extended message
+func UnmarshalACError(data []byte) (ACError, error) {
+	// Header: FF 10 ACNum Length String...
+	if len(data) < 4 {
+		return ACError{}, ErrInvalidLength
+	}
+	if data[0] != 0xFF || data[1] != ExtMsgTypeACError {
+		return ACError{}, fmt.Errorf("%w for ac error", ErrInvalidHeader)
+	}
+
+	length := int(data[3])
+	if len(data) < 4+length {
+		return ACError{}, ErrInvalidLength
+	}
+
+	msg := strings.TrimRight(string(data[4:4+length]), "\x00")
+	return ACError{
+		ACNumber: data[2],
+		Message:  msg,
+		Code:     parseACErrorCode(msg),
+	}, nil
+}
+
+// MarshalACError creates the AC Error extended message payload,
+// as sent by the device.
+func MarshalACError(e ACError) ([]byte, error) {
+	if len(e.Message) > 255 {
+		return nil, fmt.Errorf("ac %d: error info longer than 255 bytes", e.ACNumber)
+	}
+	buf := []byte{0xFF, ExtMsgTypeACError, e.ACNumber, uint8(len(e.Message))}
+	return append(buf, e.Message...), nil
+}
+
+// parseACErrorCode extracts the hex code from an error string like "ER: FFFE".
+// This matches the ErrorCode reported in ACStatus.
+func parseACErrorCode(msg string) int {
+	_, code, found := strings.Cut(msg, ":")
+	if !found {
+		code = msg
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(code), 16, 16)
+	if err != nil {
+		return 0
+	}
+	return int(v)
+}
@@ -0,0 +1,223 @@
+package at2plus
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Property tests: for every valid value x, Unmarshal(Marshal(x)) == x.
+
+const roundTripIterations = 500
+
+func newRand(t *testing.T) *rand.Rand {
+	t.Helper()
+	return rand.New(rand.NewPCG(1, uint64(len(t.Name()))))
+}
+
+// optInt returns nil or a pointer to a value in [lo, hi].
+func optInt(r *rand.Rand, lo, hi int) *int {
+	if r.IntN(3) == 0 {
+		return nil
+	}
+	v := lo + r.IntN(hi-lo+1)
+	return &v
+}
+
+// optOf returns nil or a pointer to one of values.
+func optOf[T any](r *rand.Rand, values ...T) *T {
+	if r.IntN(3) == 0 {
+		return nil
+	}
+	v := values[r.IntN(len(values))]
+	return &v
+}
+
+// optTemperature returns nil or a pointer to a temperature in [lo, hi].
+func optTemperature(r *rand.Rand, lo, hi Temperature) *Temperature {
+	if r.IntN(3) == 0 {
+		return nil
+	}
+	v := lo + Temperature(r.IntN(int(hi-lo)+1))
+	return &v
+}
+
+func randName(r *rand.Rand, maxLen int) string {
+	const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789 "
+	b := make([]byte, r.IntN(maxLen+1))
+	for i := range b {
+		b[i] = letters[r.IntN(len(letters))]
+	}
+	return string(b)
+}
+
+func TestRoundTrip_GroupControl(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		groups := make([]GroupControl, 1+r.IntN(16))
+		for j := range groups {
+			g := GroupControl{
+				GroupNumber: uint8(r.IntN(16)),
+				Power:       optOf(r, GroupPowerNext, GroupPowerOff, GroupPowerOn, GroupPowerTurbo),
+				Value:       optOf(r, GroupValueDecrease, GroupValueIncrease, GroupValueSet),
+			}
+			// Percent only carries meaning together with Set
+			if g.Value != nil && *g.Value == GroupValueSet {
+				g.Percent = optInt(r, 0, 100)
+			}
+			groups[j] = g
+		}
+
+		data, err := MarshalGroupControl(groups)
+		require.NoError(t, err)
+		got, err := UnmarshalGroupControl(data)
+		require.NoError(t, err)
+		assert.Equal(t, groups, got)
+	}
+}
+
+func TestRoundTrip_ACControl(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		acs := make([]ACControl, 1+r.IntN(8))
+		for j := range acs {
+			acs[j] = ACControl{
+				ACNumber: uint8(r.IntN(8)),
+				Power:    optOf(r, ACPowerToggle, ACPowerOff, ACPowerOn, ACPowerAway, ACPowerSleep),
+				Mode:     optOf(r, ACModeAuto, ACModeHeat, ACModeDry, ACModeFan, ACModeCool),
+				FanSpeed: optOf(r, FanSpeedAuto, FanSpeedQuiet, FanSpeedLow, FanSpeedMedium, FanSpeedHigh, FanSpeedPowerful, FanSpeedTurbo),
+				Setpoint: optTemperature(r, MinSetpoint, MaxSetpoint),
+			}
+		}
+
+		data, err := MarshalACControl(acs)
+		require.NoError(t, err)
+		got, err := UnmarshalACControl(data)
+		require.NoError(t, err)
+		assert.Equal(t, acs, got)
+	}
+}
+
+func TestRoundTrip_GroupStatus(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		groups := make([]GroupStatus, 1+r.IntN(16))
+		for j := range groups {
+			groups[j] = GroupStatus{
+				GroupNumber:  uint8(r.IntN(16)),
+				Power:        []GroupPowerState{GroupPowerStateOff, GroupPowerStateOn, GroupPowerStateTurbo}[r.IntN(3)],
+				Percent:      r.IntN(101),
+				TurboSupport: r.IntN(2) == 0,
+				Spill:        r.IntN(2) == 0,
+			}
+		}
+
+		data, err := MarshalGroupStatus(groups)
+		require.NoError(t, err)
+		got, err := UnmarshalGroupStatus(data)
+		require.NoError(t, err)
+		assert.Equal(t, groups, got)
+	}
+}
+
+func TestRoundTrip_ACStatus(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		acs := make([]ACStatus, 1+r.IntN(8))
+		for j := range acs {
+			acs[j] = ACStatus{
+				ACNumber:    uint8(r.IntN(8)),
+				Power:       []ACPowerState{0, 1, 2, 3, 5}[r.IntN(5)],
+				Mode:        []ACMode{0, 1, 2, 3, 4, 8, 9}[r.IntN(7)],
+				FanSpeed:    FanSpeed(r.IntN(7)),
+				Setpoint:    Temperature(100 + r.IntN(251)),
+				Temperature: Temperature(-500 + r.IntN(2001)),
+				Turbo:       r.IntN(2) == 0,
+				Bypass:      r.IntN(2) == 0,
+				Spill:       r.IntN(2) == 0,
+				Timer:       r.IntN(2) == 0,
+				ErrorCode:   r.IntN(0x10000),
+			}
+		}
+
+		data, err := MarshalACStatus(acs)
+		require.NoError(t, err)
+		got, err := UnmarshalACStatus(data)
+		require.NoError(t, err)
+		assert.Equal(t, acs, got)
+	}
+}
+
+func TestRoundTrip_ACAbility(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		abilities := make([]ACAbility, 1+r.IntN(4))
+		for j := range abilities {
+			abilities[j] = ACAbility{
+				ACNumber:    uint8(r.IntN(4)),
+				Name:        randName(r, 16),
+				StartGroup:  uint8(r.IntN(16)),
+				GroupCount:  uint8(r.IntN(17)),
+				CoolMode:    r.IntN(2) == 0,
+				FanMode:     r.IntN(2) == 0,
+				DryMode:     r.IntN(2) == 0,
+				HeatMode:    r.IntN(2) == 0,
+				AutoMode:    r.IntN(2) == 0,
+				FanTurbo:    r.IntN(2) == 0,
+				FanPowerful: r.IntN(2) == 0,
+				FanHigh:     r.IntN(2) == 0,
+				FanMed:      r.IntN(2) == 0,
+				FanLow:      r.IntN(2) == 0,
+				FanQuiet:    r.IntN(2) == 0,
+				FanAuto:     r.IntN(2) == 0,
+				MinCoolSet:  r.IntN(256),
+				MaxCoolSet:  r.IntN(256),
+				MinHeatSet:  r.IntN(256),
+				MaxHeatSet:  r.IntN(256),
+			}
+		}
+
+		data, err := MarshalACAbility(abilities)
+		require.NoError(t, err)
+		got, err := UnmarshalACAbility(data)
+		require.NoError(t, err)
+		assert.Equal(t, abilities, got)
+	}
+}
+
+func TestRoundTrip_GroupName(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		names := make([]GroupName, 1+r.IntN(16))
+		for j := range names {
+			names[j] = GroupName{GroupNumber: uint8(r.IntN(16)), Name: randName(r, 8)}
+		}
+
+		data, err := MarshalGroupName(names)
+		require.NoError(t, err)
+		got, err := UnmarshalGroupName(data)
+		require.NoError(t, err)
+		assert.Equal(t, names, got)
+	}
+}
+
+func TestRoundTrip_ACError(t *testing.T) {
+	r := newRand(t)
+	for i := 0; i < roundTripIterations; i++ {
+		code := r.IntN(0x10000)
+		e := ACError{ACNumber: uint8(r.IntN(4))}
+		if code != 0 {
+			e.Message = "ER: " + fmt.Sprintf("%04X", code)
+			e.Code = code
+		}
+
+		data, err := MarshalACError(e)
+		require.NoError(t, err)
+		got, err := UnmarshalACError(data)
+		require.NoError(t, err)
+		assert.Equal(t, e, got)
+	}
+}
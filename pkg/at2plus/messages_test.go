@@ -2,6 +2,7 @@ package at2plus
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,12 +15,10 @@ func TestMarshalGroupControl_SpecExample(t *testing.T) {
 
 	// Data: 0x20 0x00 0x00 0x00 0x00 0x01 0x00 0x04 0x01 0x02 0x00 0x00
 
-	powerOff := 1 // My struct: 0:Next, 1:Off, 2:On
-
 	groups := []GroupControl{
 		{
 			GroupNumber: 1,
-			Power:       &powerOff,
+			Power:       Ptr(GroupPowerOff),
 		},
 	}
 
@@ -42,13 +41,13 @@ func TestUnmarshalGroupStatus_SpecExample(t *testing.T) {
 
 	// Group 0
 	assert.Equal(t, uint8(0), groups[0].GroupNumber)
-	assert.Equal(t, 0, groups[0].Power) // Off
+	assert.Equal(t, GroupPowerStateOff, groups[0].Power)
 	assert.Equal(t, 0, groups[0].Percent)
 	assert.True(t, groups[0].TurboSupport) // 0x80 -> Bit 8 is 1
 
 	// Group 1
 	assert.Equal(t, uint8(1), groups[1].GroupNumber)
-	assert.Equal(t, 1, groups[1].Power)    // On
+	assert.Equal(t, GroupPowerStateOn, groups[1].Power)
 	assert.Equal(t, 50, groups[1].Percent) // 0x32 = 50
 	assert.False(t, groups[1].TurboSupport)
 	assert.True(t, groups[1].Spill) // 0x02 -> Bit 2 is 1
@@ -72,11 +71,11 @@ func TestUnmarshalACStatus_SpecExample(t *testing.T) {
 
 	ac := acs[0]
 	assert.Equal(t, uint8(0), ac.ACNumber)
-	assert.Equal(t, 1, ac.Power)    // On
-	assert.Equal(t, 1, ac.Mode)     // Heat
-	assert.Equal(t, 2, ac.FanSpeed) // Low
-	assert.Equal(t, 22, ac.Setpoint)
-	assert.Equal(t, 23, ac.Temperature)
+	assert.Equal(t, ACPowerStateOn, ac.Power)
+	assert.Equal(t, ACModeHeat, ac.Mode)
+	assert.Equal(t, FanSpeedLow, ac.FanSpeed)
+	assert.Equal(t, Celsius(22), ac.Setpoint)
+	assert.Equal(t, Celsius(23), ac.Temperature)
 	assert.Equal(t, 0, ac.ErrorCode)
 }
 
@@ -129,6 +128,22 @@ func TestUnmarshalACAbility_SpecExample(t *testing.T) {
 	assert.False(t, a.CoolMode) // Spec text says Cool, but hex 0x17 says No Cool (Bit 5 is 0). I will trust Hex.
 }
 
+func TestUnmarshalACAbility_ShortChunk(t *testing.T) {
+	// AC 0 claims 4 bytes of ability data
+	data, _ := hex.DecodeString("ff110004554e4954")
+
+	_, err := UnmarshalACAbility(data)
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
+
+func TestUnmarshalACAbility_Truncated(t *testing.T) {
+	// AC 0 claims 24 bytes but carries 12
+	data, _ := hex.DecodeString("ff110018554e49540000000000000000")
+
+	_, err := UnmarshalACAbility(data)
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
+
 func TestUnmarshalGroupName_SpecExample(t *testing.T) {
 	// Spec Page 14: Group 0 "Group1"
 	// ff 12 00 47 72 6f 75 70 31 00 00
@@ -145,14 +160,11 @@ func TestUnmarshalGroupName_SpecExample(t *testing.T) {
 }
 
 func TestMarshalACControl_SingleAC(t *testing.T) {
-	powerOn := 3 // On
-	mode := 4    // Cool
-
 	acs := []ACControl{
 		{
 			ACNumber: 0,
-			Power:    &powerOn,
-			Mode:     &mode,
+			Power:    Ptr(ACPowerOn),
+			Mode:     Ptr(ACModeCool),
 		},
 	}
 
@@ -162,23 +174,20 @@ func TestMarshalACControl_SingleAC(t *testing.T) {
 	// Header: 22 00 00 00 00 01 00 04 (SubType, 0s, count=1, repeatLen=4)
 	// Data: AC0 with power=3 (on), mode=4 (cool)
 	// Byte1: (power<<4) | acNum = (3<<4) | 0 = 0x30
-	// Byte2: (mode<<4) | fanSpeed = (4<<4) | 0 = 0x40
+	// Byte2: (mode<<4) | fanSpeed = (4<<4) | 0xF (keep) = 0x4F
 	// Byte3: 0x00 (no setpoint change)
-	// Byte4: 0x00
+	// Byte4: 0xFF
 
 	assert.Equal(t, byte(SubMsgTypeACControl), data[0])
 	assert.Equal(t, byte(0x30), data[8])  // power on, AC 0
-	assert.Equal(t, byte(0x40), data[9])  // cool mode
+	assert.Equal(t, byte(0x4F), data[9])  // cool mode, keep fan speed
 	assert.Equal(t, byte(0x00), data[10]) // no setpoint
 }
 
 func TestMarshalACControl_MultipleACs(t *testing.T) {
-	powerOff := 2 // Off
-	powerOn := 3  // On
-
 	acs := []ACControl{
-		{ACNumber: 0, Power: &powerOff},
-		{ACNumber: 1, Power: &powerOn},
+		{ACNumber: 0, Power: Ptr(ACPowerOff)},
+		{ACNumber: 1, Power: Ptr(ACPowerOn)},
 	}
 
 	data, err := MarshalACControl(acs)
@@ -195,10 +204,8 @@ func TestMarshalACControl_MultipleACs(t *testing.T) {
 }
 
 func TestMarshalACControl_WithSetpoint(t *testing.T) {
-	setpoint := 24
-
 	acs := []ACControl{
-		{ACNumber: 0, Setpoint: &setpoint},
+		{ACNumber: 0, Setpoint: Ptr(Celsius(24))},
 	}
 
 	data, err := MarshalACControl(acs)
@@ -245,3 +252,371 @@ func TestUnmarshalACStatus_TooShort(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrInvalidLength)
 }
+
+func TestUnmarshalStatus_ShortRepeatLength(t *testing.T) {
+	// One entry with a repeat length of 0, then of 4
+	for _, repeat := range []string{"0000", "000400000000"} {
+		data, _ := hex.DecodeString("210000000001" + repeat)
+		_, err := UnmarshalGroupStatus(data)
+		assert.ErrorIs(t, err, ErrInvalidLength, "group status %x", data)
+
+		data[0] = SubMsgTypeACStatus
+		_, err = UnmarshalACStatus(data)
+		assert.ErrorIs(t, err, ErrInvalidLength, "AC status %x", data)
+	}
+}
+
+func TestUnmarshalACError_SpecExample(t *testing.T) {
+	// Spec Page 13: AC 0 error "ER: FFFE"
+	// ff 10 00 08 45 52 3a 20 46 46 46 45
+
+	data, _ := hex.DecodeString("ff10000845523a2046464645")
+
+	acErr, err := UnmarshalACError(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint8(0), acErr.ACNumber)
+	assert.Equal(t, "ER: FFFE", acErr.Message)
+	assert.Equal(t, 0xFFFE, acErr.Code)
+}
+
+func TestUnmarshalACError_NoError(t *testing.T) {
+	data, _ := hex.DecodeString("ff100100")
+
+	acErr, err := UnmarshalACError(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint8(1), acErr.ACNumber)
+	assert.Empty(t, acErr.Message)
+	assert.Equal(t, 0, acErr.Code)
+}
+
+func TestUnmarshalACError_Truncated(t *testing.T) {
+	// Length byte claims 8 bytes but only 3 follow
+	data, _ := hex.DecodeString("ff10000845523a")
+
+	_, err := UnmarshalACError(data)
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
+
+func TestUnmarshalACError_InvalidHeader(t *testing.T) {
+	data, _ := hex.DecodeString("ff110000")
+
+	_, err := UnmarshalACError(data)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalACStatus_ErrorCode(t *testing.T) {
+	// AC 0 reporting error 0xFFFE in bytes 7-8
+	data, _ := hex.DecodeString("230000000001000A101278C002DAFFFE8000")
+
+	acs, err := UnmarshalACStatus(data)
+	require.NoError(t, err)
+	require.Len(t, acs, 1)
+	assert.Equal(t, 0xFFFE, acs[0].ErrorCode)
+}
+
+func TestMarshalACControl_SpecExample_TurnOffSecondAC(t *testing.T) {
+	// Spec Page 8: Turn off the second AC
+	// Data: 0x22 0x00 0x00 0x00 0x00 0x01 0x00 0x04 0x21 0xFF 0x00 0xFF
+
+	data, err := MarshalACControl([]ACControl{{ACNumber: 1, Power: Ptr(ACPowerOff)}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2200000000010004"+"21ff00ff", hex.EncodeToString(data))
+}
+
+func TestMarshalACControl_SpecExample_CoolAndSetpoint(t *testing.T) {
+	// Spec Page 8-9: Set the first AC to cool mode and second AC to 26 degrees
+	// Data: 0x22 ... 0x00 0x4F 0x00 0xFF 0x01 0xFF 0x40 0xA0
+
+	data, err := MarshalACControl([]ACControl{
+		{ACNumber: 0, Mode: Ptr(ACModeCool)},
+		{ACNumber: 1, Setpoint: Ptr(Celsius(26))},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2200000000020004"+"004f00ff"+"01ff40a0", hex.EncodeToString(data))
+}
+
+func TestMarshalGroupControl_SpecExample_SetPercent(t *testing.T) {
+	// Spec Page 5: Set first and second groups to open 10%
+	// Data: 0x20 ... 0x00 0x80 0x0A 0x00 0x01 0x80 0x0A 0x00
+
+	data, err := MarshalGroupControl([]GroupControl{
+		{GroupNumber: 0, Value: Ptr(GroupValueSet), Percent: Ptr(10)},
+		{GroupNumber: 1, Percent: Ptr(10)}, // Set is implied by Percent
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2000000000020004"+"00800a00"+"01800a00", hex.EncodeToString(data))
+}
+
+func TestUnmarshalACControl_SpecExample(t *testing.T) {
+	data, _ := hex.DecodeString("2200000000020004004f00ff01ff40a0")
+
+	acs, err := UnmarshalACControl(data)
+	require.NoError(t, err)
+	require.Len(t, acs, 2)
+
+	assert.Nil(t, acs[0].Power)
+	require.NotNil(t, acs[0].Mode)
+	assert.Equal(t, ACModeCool, *acs[0].Mode)
+	assert.Nil(t, acs[0].FanSpeed)
+	assert.Nil(t, acs[0].Setpoint)
+
+	assert.Equal(t, uint8(1), acs[1].ACNumber)
+	assert.Nil(t, acs[1].Mode)
+	require.NotNil(t, acs[1].Setpoint)
+	assert.Equal(t, Celsius(26), *acs[1].Setpoint)
+}
+
+func TestUnmarshalGroupControl_SpecExample(t *testing.T) {
+	data, _ := hex.DecodeString("200000000001000401020000")
+
+	groups, err := UnmarshalGroupControl(data)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+
+	assert.Equal(t, uint8(1), groups[0].GroupNumber)
+	require.NotNil(t, groups[0].Power)
+	assert.Equal(t, GroupPowerOff, *groups[0].Power)
+	assert.Nil(t, groups[0].Value)
+	assert.Nil(t, groups[0].Percent)
+}
+
+func TestUnmarshalControl_InvalidSubType(t *testing.T) {
+	data, _ := hex.DecodeString("200000000001000401020000")
+
+	_, err := UnmarshalACControl(data)
+	assert.Error(t, err)
+
+	data[0] = SubMsgTypeACControl
+	_, err = UnmarshalGroupControl(data)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalControl_Truncated(t *testing.T) {
+	// Claims 2 entries but carries one
+	data, _ := hex.DecodeString("220000000002000421ff00ff")
+
+	_, err := UnmarshalACControl(data)
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
+
+func TestMarshalStatus_SpecExamples(t *testing.T) {
+	groups, err := UnmarshalGroupStatus(specGroupStatusData)
+	require.NoError(t, err)
+	data, err := MarshalGroupStatus(groups)
+	require.NoError(t, err)
+	assert.Equal(t, specGroupStatusData, data)
+
+	acData, _ := hex.DecodeString("23000000000200" + "0a" + "101278c002da00008000" + "014264c002e400008000")
+	acs, err := UnmarshalACStatus(acData)
+	require.NoError(t, err)
+	data, err = MarshalACStatus(acs)
+	require.NoError(t, err)
+	// Unused bits (Byte4 Bit8-5 and Byte9-10) are not preserved
+	assert.Equal(t, "23000000000200"+"0a"+"1012780002da00000000"+"0142640002e400000000", hex.EncodeToString(data))
+}
+
+func TestMarshalExtended_SpecExamples(t *testing.T) {
+	abilityData, _ := hex.DecodeString("ff110018554e49540000000000000000000000000004171d111f111f")
+	abilities, err := UnmarshalACAbility(abilityData)
+	require.NoError(t, err)
+	data, err := MarshalACAbility(abilities)
+	require.NoError(t, err)
+	// Unused bits (Byte23 Bit1 and Byte24 Bit1 in the example) are not preserved
+	assert.Equal(t, "ff110018554e49540000000000000000000000000004161c111f111f", hex.EncodeToString(data))
+
+	nameData, _ := hex.DecodeString("ff1200" + "4c6976696e670000" + "01" + "4b69746368656e00" + "02" + "426564726f6f6d00")
+	names, err := UnmarshalGroupName(nameData)
+	require.NoError(t, err)
+	data, err = MarshalGroupName(names)
+	require.NoError(t, err)
+	assert.Equal(t, nameData, data)
+
+	errData, _ := hex.DecodeString("ff10000845523a2046464645")
+	acErr, err := UnmarshalACError(errData)
+	require.NoError(t, err)
+	data, err = MarshalACError(acErr)
+	require.NoError(t, err)
+	assert.Equal(t, errData, data)
+}
+
+func TestMarshal_InvalidValues(t *testing.T) {
+	_, err := MarshalGroupStatus([]GroupStatus{{GroupNumber: 16}})
+	assert.Error(t, err)
+	_, err = MarshalGroupStatus([]GroupStatus{{Percent: 101}})
+	assert.Error(t, err)
+	_, err = MarshalACStatus([]ACStatus{{Setpoint: Celsius(40)}})
+	assert.Error(t, err)
+	_, err = MarshalACStatus([]ACStatus{{Setpoint: Celsius(20), Temperature: Celsius(-60)}})
+	assert.Error(t, err)
+	_, err = MarshalACAbility([]ACAbility{{Name: "seventeen chars!!"}})
+	assert.Error(t, err)
+	_, err = MarshalGroupName([]GroupName{{Name: "TooLongName"}})
+	assert.Error(t, err)
+}
+
+func TestMarshalControl_InvalidEnums(t *testing.T) {
+	_, err := MarshalGroupControl([]GroupControl{{Power: Ptr(GroupPowerCommand(4))}})
+	assert.Error(t, err)
+	_, err = MarshalGroupControl([]GroupControl{{Value: Ptr(GroupValue(1))}})
+	assert.Error(t, err)
+	_, err = MarshalACControl([]ACControl{{Power: Ptr(ACPowerCommand(0))}})
+	assert.Error(t, err)
+	_, err = MarshalACControl([]ACControl{{Mode: Ptr(ACModeAutoCool)}})
+	assert.Error(t, err)
+	_, err = MarshalACControl([]ACControl{{FanSpeed: Ptr(FanSpeed(7))}})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalACStatus_FractionalTemperature(t *testing.T) {
+	// Setpoint 0x7D = 125 -> 22.5, Temperature 0x02E1 = 737 -> 23.7,
+	// the reading the old integer division reported as 23
+	data, _ := hex.DecodeString("230000000001000A10127DC002E100008000")
+
+	acs, err := UnmarshalACStatus(data)
+	require.NoError(t, err)
+	require.Len(t, acs, 1)
+	assert.Equal(t, Celsius(22.5), acs[0].Setpoint)
+	assert.Equal(t, Celsius(23.7), acs[0].Temperature)
+}
+
+func TestUnmarshalACStatus_NegativeTemperature(t *testing.T) {
+	// Temperature 0x01E7 = 487 -> -1.3
+	data, _ := hex.DecodeString("230000000001000A101278C001E700008000")
+
+	acs, err := UnmarshalACStatus(data)
+	require.NoError(t, err)
+	assert.Equal(t, Celsius(-1.3), acs[0].Temperature)
+}
+
+func TestMarshalACControl_FractionalSetpoint(t *testing.T) {
+	data, err := MarshalACControl([]ACControl{{ACNumber: 0, Setpoint: Ptr(Celsius(22.5))}})
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x40), data[10])
+	assert.Equal(t, byte(125), data[11])
+}
+
+func TestMarshalACControl_SetpointOutOfRange(t *testing.T) {
+	_, err := MarshalACControl([]ACControl{{Setpoint: Ptr(Celsius(9.9))}})
+	assert.Error(t, err)
+
+	_, err = MarshalACControl([]ACControl{{Setpoint: Ptr(Celsius(35.1))}})
+	assert.Error(t, err)
+}
+
+func BenchmarkUnmarshalGroupStatus(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := UnmarshalGroupStatus(specGroupStatusData); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalACStatus(b *testing.B) {
+	data, _ := hex.DecodeString("230000000001000A10127DC002E100008000")
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := UnmarshalACStatus(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The JSON encoding of the status types is relied on by scripts using the
+// CLI's --output json; changing it breaks them.
+func TestStatus_JSONSchema(t *testing.T) {
+	data, err := json.Marshal(GroupStatus{GroupNumber: 1, Power: GroupPowerStateOn, Percent: 60, TurboSupport: true})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"group":1,"power":"on","percent":60,"turbo_support":true,"spill":false}`, string(data))
+
+	data, err = json.Marshal(ACStatus{
+		ACNumber: 0, Power: ACPowerStateOn, Mode: ACModeCool, FanSpeed: FanSpeedAuto,
+		Setpoint: Celsius(22.5), Temperature: Celsius(24), ErrorCode: 0xFFFE,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ac":0,"power":"on","mode":"cool","fan_speed":"auto","setpoint":22.5,"temperature":24,
+		"turbo":false,"bypass":false,"spill":false,"timer":false,"error_code":65534}`, string(data))
+
+	data, err = json.Marshal(DiscoveryResult{IP: "192.168.1.50", Port: 9200, Verified: true, GroupCount: 4, ACCount: 1, ACNames: []string{"UNIT"}, Latency: 3000000})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ip":"192.168.1.50","port":9200,"verified":true,"group_count":4,"ac_count":1,"ac_names":["UNIT"],"latency_ns":3000000}`, string(data))
+
+	var ac ACStatus
+	require.NoError(t, json.Unmarshal([]byte(`{"ac":1,"mode":"heat","setpoint":21}`), &ac))
+	assert.Equal(t, ACStatus{ACNumber: 1, Mode: ACModeHeat, Setpoint: Celsius(21)}, ac)
+}
+
+// fuzzDecoder checks that decode returns rather than panics on any input.
+func fuzzDecoder(f *testing.F, decode func([]byte) error, seeds ...string) {
+	for _, s := range seeds {
+		data, err := hex.DecodeString(s)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_ = decode(data)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := Decode(data)
+		return err
+	}, "5555B08001C00018210000000002000800000000000080004132000000000200832F", "55550072d5c0fffe00000000")
+}
+
+func FuzzUnmarshalGroupStatus(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalGroupStatus(data)
+		return err
+	}, "210000000002000800000000000080004132000000000200", "2100000000010000")
+}
+
+func FuzzUnmarshalACStatus(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalACStatus(data)
+		return err
+	}, "230000000001000A101278C002DA00008000", "2300000000010000")
+}
+
+func FuzzUnmarshalGroupControl(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalGroupControl(data)
+		return err
+	}, "200000000001000401020000")
+}
+
+func FuzzUnmarshalACControl(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalACControl(data)
+		return err
+	}, "2200000000020004004f00ff01ff40a0")
+}
+
+func FuzzUnmarshalACAbility(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalACAbility(data)
+		return err
+	}, "ff110018554e49540000000000000000000000000004171d111f111f", "ff110004554e4954")
+}
+
+func FuzzUnmarshalGroupName(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalGroupName(data)
+		return err
+	}, "ff1200"+"4c6976696e670000"+"01"+"4b69746368656e00")
+}
+
+func FuzzUnmarshalACError(f *testing.F) {
+	fuzzDecoder(f, func(data []byte) error {
+		_, err := UnmarshalACError(data)
+		return err
+	}, "ff10000845523a2046464645", "ff10000845523a")
+}
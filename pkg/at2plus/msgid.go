@@ -0,0 +1,84 @@
+package at2plus
+
+import (
+	"time"
+)
+
+// msgIDQuarantine is how long the ID of a request that timed out is kept
+// out of use, so that a late response is recognised as such instead of
+// being delivered to a newer request that reused the ID.
+const msgIDQuarantine = 10 * time.Second
+
+// msgIDAllocator hands out message IDs for requests. IDs of requests in
+// flight and recently timed-out requests are skipped. ID 0 is never
+// allocated: it is left to unsolicited messages from the device.
+// Access is guarded by Client.pendingMu.
+type msgIDAllocator struct {
+	next       uint8
+	used       [256]bool
+	quarantine map[uint8]time.Time // ID -> end of quarantine
+}
+
+// alloc returns a free ID. If every ID is in use or quarantined it
+// reuses the quarantined ID whose quarantine ends first, and fails only
+// when all IDs belong to live requests.
+func (a *msgIDAllocator) alloc(now time.Time) (uint8, bool) {
+	var (
+		oldest   uint8
+		oldestAt time.Time
+	)
+	for range 255 {
+		a.next++
+		if a.next == 0 {
+			a.next = 1
+		}
+		id := a.next
+		if a.used[id] {
+			continue
+		}
+		if until, ok := a.quarantine[id]; ok {
+			if now.Before(until) {
+				if oldest == 0 || until.Before(oldestAt) {
+					oldest, oldestAt = id, until
+				}
+				continue
+			}
+			delete(a.quarantine, id)
+		}
+		a.used[id] = true
+		return id, true
+	}
+
+	if oldest == 0 {
+		return 0, false
+	}
+	delete(a.quarantine, oldest)
+	a.used[oldest] = true
+	return oldest, true
+}
+
+// release returns an ID whose request got its response or was never sent.
+func (a *msgIDAllocator) release(id uint8) {
+	a.used[id] = false
+}
+
+// expire releases the ID of a request that was sent but got no response,
+// keeping it out of use until a late response could no longer arrive.
+func (a *msgIDAllocator) expire(id uint8, now time.Time) {
+	a.used[id] = false
+	if a.quarantine == nil {
+		a.quarantine = make(map[uint8]time.Time)
+	}
+	a.quarantine[id] = now.Add(msgIDQuarantine)
+}
+
+// late reports whether id belongs to a request that timed out, and ends
+// its quarantine: the late response has now arrived.
+func (a *msgIDAllocator) late(id uint8, now time.Time) bool {
+	until, ok := a.quarantine[id]
+	if !ok {
+		return false
+	}
+	delete(a.quarantine, id)
+	return now.Before(until)
+}
@@ -0,0 +1,114 @@
+package at2plus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgIDAllocator_SkipsZeroAndUsed(t *testing.T) {
+	var a msgIDAllocator
+	now := time.Now()
+
+	seen := make(map[uint8]bool)
+	for range 255 {
+		id, ok := a.alloc(now)
+		require.True(t, ok)
+		assert.NotZero(t, id)
+		assert.False(t, seen[id], "ID %d allocated twice", id)
+		seen[id] = true
+	}
+	_, ok := a.alloc(now)
+	assert.False(t, ok, "all IDs are in use")
+
+	a.release(42)
+	id, ok := a.alloc(now)
+	require.True(t, ok)
+	assert.Equal(t, uint8(42), id)
+}
+
+func TestMsgIDAllocator_Quarantine(t *testing.T) {
+	var a msgIDAllocator
+	now := time.Now()
+
+	first, _ := a.alloc(now)
+	a.expire(first, now)
+
+	// The quarantined ID is skipped on wraparound
+	for range 300 {
+		id, ok := a.alloc(now)
+		require.True(t, ok)
+		assert.NotEqual(t, first, id)
+		a.release(id)
+	}
+
+	// After the grace period it is free again
+	later := now.Add(msgIDQuarantine + time.Second)
+	for range 255 {
+		id, _ := a.alloc(later)
+		if id == first {
+			return
+		}
+		a.release(id)
+	}
+	t.Fatal("quarantined ID never reused")
+}
+
+func TestMsgIDAllocator_Late(t *testing.T) {
+	var a msgIDAllocator
+	now := time.Now()
+
+	id, _ := a.alloc(now)
+	assert.False(t, a.late(id, now))
+
+	a.expire(id, now)
+	assert.True(t, a.late(id, now))
+	assert.False(t, a.late(id, now), "quarantine ends with the late response")
+}
+
+func TestMsgIDAllocator_ReusesOldestQuarantined(t *testing.T) {
+	var a msgIDAllocator
+	now := time.Now()
+
+	for i := range 255 {
+		id, _ := a.alloc(now)
+		a.expire(id, now.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	id, ok := a.alloc(now)
+	require.True(t, ok)
+	assert.Equal(t, uint8(1), id)
+}
+
+func TestClient_LateResponseDiscarded(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t, WithRequestTimeout(50*time.Millisecond))
+	sub, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	_, err = client.GetGroupStatus(context.Background())
+	require.ErrorIs(t, err, ErrTimeout)
+	timedOut := readRequest(t, conn)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := readRequest(t, conn)
+		assert.NotEqual(t, timedOut.MsgID, req.MsgID)
+
+		// The late reply comes first and must not answer the new request
+		writeResponse(t, conn, timedOut.MsgID, MsgTypeControlStatus, specGroupStatusData)
+		acStatus, _ := MarshalACStatus([]ACStatus{{ACNumber: 1, Setpoint: Celsius(22)}})
+		writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, acStatus)
+	}()
+
+	acs, err := client.GetACStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), acs[0].ACNumber)
+	<-done
+
+	assert.Equal(t, uint64(1), client.Stats().LateResponses)
+	assert.Empty(t, sub.Updates(), "late response must not be published")
+}
@@ -0,0 +1,178 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownName is returned when a group or AC reference matches no name.
+var ErrUnknownName = errors.New("unknown name")
+
+// AmbiguousNameError is returned when a group or AC reference matches
+// more than one name.
+type AmbiguousNameError struct {
+	// Kind is "group" or "AC".
+	Kind string
+	// Ref is the reference as given.
+	Ref string
+	// Numbers are the group or AC numbers whose names match.
+	Numbers []uint8
+	// Names are the matching names, in the order of Numbers.
+	Names []string
+}
+
+// Error implements the error interface.
+func (e *AmbiguousNameError) Error() string {
+	matches := make([]string, len(e.Numbers))
+	for i, n := range e.Numbers {
+		matches[i] = fmt.Sprintf("%s %d (%s)", e.Kind, n, e.Names[i])
+	}
+	return fmt.Sprintf("%q is ambiguous: matches %s", e.Ref, strings.Join(matches, ", "))
+}
+
+// unitName is the name of a group or AC.
+type unitName struct {
+	number uint8
+	name   string
+}
+
+// matchName resolves ref against names. A number is taken as is. A name
+// matches case-insensitively, in full or, failing that, by prefix; it must
+// match exactly one unit.
+func matchName(kind, ref string, names []unitName) (uint8, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return 0, fmt.Errorf("empty %s name: %w", kind, ErrUnknownName)
+	}
+	if n, err := strconv.ParseUint(ref, 10, 8); err == nil {
+		if n > 15 {
+			return 0, fmt.Errorf("%s number %d out of range 0-15", kind, n)
+		}
+		return uint8(n), nil
+	}
+
+	var exact, prefix []unitName
+	for _, u := range names {
+		name := strings.TrimSpace(u.name)
+		switch {
+		case strings.EqualFold(name, ref):
+			exact = append(exact, u)
+		case strings.HasPrefix(strings.ToLower(name), strings.ToLower(ref)):
+			prefix = append(prefix, u)
+		}
+	}
+	matches := exact
+	if len(matches) == 0 {
+		matches = prefix
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("%s %q: %w", kind, ref, ErrUnknownName)
+	case 1:
+		return matches[0].number, nil
+	}
+	e := &AmbiguousNameError{Kind: kind, Ref: ref}
+	for _, u := range matches {
+		e.Numbers = append(e.Numbers, u.number)
+		e.Names = append(e.Names, u.name)
+	}
+	return 0, e
+}
+
+// ResolveGroup returns the number of the group that ref names. ref is a
+// group number or a group name, matched case-insensitively and in full or
+// by a unique prefix ("kitchen", "bed 2"). Names are fetched on first use
+// and cached; they are fetched again when ref matches none of them, so
+// groups renamed on the console are found.
+//
+// An unknown name returns an error matching ErrUnknownName; a name that
+// matches several groups returns an *AmbiguousNameError.
+func (c *Client) ResolveGroup(ctx context.Context, ref string) (uint8, error) {
+	return c.resolveName(ctx, "group", ref, &c.groupNames, c.loadGroupNames)
+}
+
+// ResolveAC returns the number of the AC that ref names, like
+// ResolveGroup. AC names are those of their abilities.
+func (c *Client) ResolveAC(ctx context.Context, ref string) (uint8, error) {
+	return c.resolveName(ctx, "AC", ref, &c.acNames, c.loadACNames)
+}
+
+// resolveName resolves ref against the names cached in *cache, loading
+// them with load if they are not cached yet or ref matches none of them.
+func (c *Client) resolveName(ctx context.Context, kind, ref string, cache *[]unitName, load func(context.Context) ([]unitName, error)) (uint8, error) {
+	c.nameMu.Lock()
+	names := *cache
+	c.nameMu.Unlock()
+
+	if names != nil {
+		n, err := matchName(kind, ref, names)
+		if !errors.Is(err, ErrUnknownName) {
+			return n, err
+		}
+	}
+
+	names, err := load(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("resolve %s %q: %w", kind, ref, err)
+	}
+	c.nameMu.Lock()
+	*cache = names
+	c.nameMu.Unlock()
+	return matchName(kind, ref, names)
+}
+
+// loadGroupNames fetches the names of all groups.
+func (c *Client) loadGroupNames(ctx context.Context) ([]unitName, error) {
+	groups, err := c.GetGroupNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]unitName, len(groups))
+	for i, g := range groups {
+		names[i] = unitName{number: g.GroupNumber, name: g.Name}
+	}
+	return names, nil
+}
+
+// loadACNames fetches the list of ACs and their names.
+func (c *Client) loadACNames(ctx context.Context) ([]unitName, error) {
+	acs, err := c.GetACStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]unitName, 0, len(acs))
+	for _, ac := range acs {
+		ab, err := c.ACAbilityCached(ctx, ac.ACNumber)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, unitName{number: ac.ACNumber, name: ab.Name})
+	}
+	return names, nil
+}
+
+// SetGroup sends a control command to the group that ref names, as a
+// number or a name (see ResolveGroup). The GroupNumber of ctrl is ignored.
+func (c *Client) SetGroup(ctx context.Context, ref string, ctrl GroupControl) error {
+	num, err := c.ResolveGroup(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("set group control: %w", err)
+	}
+	ctrl.GroupNumber = num
+	return c.SetGroupControl(ctx, []GroupControl{ctrl})
+}
+
+// SetAC sends a control command to the AC that ref names, as a number or
+// a name (see ResolveAC). The ACNumber of ctrl is ignored.
+func (c *Client) SetAC(ctx context.Context, ref string, ctrl ACControl) error {
+	num, err := c.ResolveAC(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("set AC control: %w", err)
+	}
+	ctrl.ACNumber = num
+	return c.SetACControl(ctx, []ACControl{ctrl})
+}
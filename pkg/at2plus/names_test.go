@@ -0,0 +1,52 @@
+package at2plus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchName(t *testing.T) {
+	names := []unitName{
+		{0, "Living"},
+		{1, "Kitchen"},
+		{2, "Bed 1"},
+		{3, "Bed 2"},
+		{4, "Bed"},
+		{5, "Study "},
+	}
+
+	tests := []struct {
+		ref  string
+		want uint8
+	}{
+		{"kitchen", 1},
+		{"KITCHEN", 1},
+		{"kit", 1},
+		{"bed 2", 3},
+		{"bed", 4}, // exact match beats prefix matches
+		{"study", 5},
+		{"3", 3},
+		{" 12 ", 12}, // numbers are not checked against the names
+	}
+	for _, tt := range tests {
+		got, err := matchName("group", tt.ref, names)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, tt.want, got, tt.ref)
+	}
+
+	_, err := matchName("group", "garage", names)
+	assert.True(t, errors.Is(err, ErrUnknownName))
+	_, err = matchName("group", "", names)
+	assert.True(t, errors.Is(err, ErrUnknownName))
+	_, err = matchName("group", "16", names)
+	assert.Error(t, err)
+
+	_, err = matchName("group", "bed ", names[:4])
+	var ae *AmbiguousNameError
+	require.True(t, errors.As(err, &ae))
+	assert.Equal(t, []uint8{2, 3}, ae.Numbers)
+	assert.Equal(t, `"bed" is ambiguous: matches group 2 (Bed 1), group 3 (Bed 2)`, err.Error())
+}
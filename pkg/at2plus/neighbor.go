@@ -0,0 +1,172 @@
+package at2plus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// arpTablePath is the kernel IPv4 neighbour table on Linux. The kernel
+// adds an entry as soon as it exchanges a packet with a host, so every
+// device discovery reaches on the local network is in it.
+var arpTablePath = "/proc/net/arp"
+
+// atfComplete is the ATF_COM flag of a resolved neighbour table entry.
+const atfComplete = 0x2
+
+// readARPTable returns the MAC addresses of the resolved entries of the
+// kernel neighbour table, by IP. It fails where the table is not
+// available, i.e. on systems other than Linux.
+func readARPTable() (map[string]net.HardwareAddr, error) {
+	f, err := os.Open(arpTablePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseARPTable(f)
+}
+
+// parseARPTable parses the /proc/net/arp format:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.1.50     0x1         0x2         a4:cf:12:bd:3e:01     *        wlan0
+func parseARPTable(r io.Reader) (map[string]net.HardwareAddr, error) {
+	table := make(map[string]net.HardwareAddr)
+	sc := bufio.NewScanner(r)
+	for first := true; sc.Scan(); first = false {
+		fields := strings.Fields(sc.Text())
+		if first || len(fields) < 4 {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[2], 0, 32)
+		if err != nil || flags&atfComplete == 0 {
+			continue
+		}
+		mac, err := net.ParseMAC(fields[3])
+		if err != nil || bytes.Count(mac, []byte{0}) == len(mac) {
+			continue
+		}
+		table[fields[0]] = mac
+	}
+	return table, sc.Err()
+}
+
+// ouiVendors maps the organizationally unique identifier (the first three
+// bytes of a MAC address) of vendors whose network modules are common in
+// embedded controllers to the vendor name.
+var ouiVendors = map[[3]byte]string{
+	{0x24, 0x0a, 0xc4}: "Espressif",
+	{0x30, 0xae, 0xa4}: "Espressif",
+	{0xa4, 0xcf, 0x12}: "Espressif",
+	{0xb8, 0x27, 0xeb}: "Raspberry Pi",
+	{0xdc, 0xa6, 0x32}: "Raspberry Pi",
+	{0xe4, 0x5f, 0x01}: "Raspberry Pi",
+}
+
+// ouiVendor returns a hint of who made the network interface with the
+// given MAC address, or "" if the vendor is not known.
+func ouiVendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+	if mac[0]&0x02 != 0 {
+		return "locally administered"
+	}
+	return ouiVendors[[3]byte(mac[:3])]
+}
+
+// deviceID returns the stable ID of a discovered device: its MAC address,
+// or the console ID if the MAC address is not known.
+func deviceID(res DiscoveryResult) string {
+	if mac, err := net.ParseMAC(res.MAC); err == nil {
+		return "mac:" + strings.ReplaceAll(mac.String(), ":", "")
+	}
+	if res.ConsoleID != "" {
+		return "console:" + res.ConsoleID
+	}
+	return ""
+}
+
+// identify fills in the MAC address, vendor hint and device ID of res.
+// arp is the neighbour table; it is re-read when it has no entry for the
+// host, since the entry appears when discovery first reaches it.
+func identify(res *DiscoveryResult, arp *map[string]net.HardwareAddr) {
+	if res.MAC == "" {
+		mac, ok := (*arp)[res.IP]
+		if !ok {
+			if table, err := readARPTable(); err == nil {
+				*arp = table
+				mac = table[res.IP]
+			}
+		}
+		if mac != nil {
+			res.MAC = mac.String()
+			res.Vendor = ouiVendor(mac)
+		}
+	}
+	res.DeviceID = deviceID(*res)
+}
+
+// isDeviceRef reports whether s refers to a device by MAC address or
+// device ID rather than by IP address or host name.
+func isDeviceRef(s string) bool {
+	if strings.HasPrefix(s, "mac:") || strings.HasPrefix(s, "console:") {
+		return true
+	}
+	if net.ParseIP(s) != nil {
+		return false
+	}
+	_, err := net.ParseMAC(s)
+	return err == nil
+}
+
+// matchesDevice reports whether res is the device ref refers to: a MAC
+// address, a device ID or a console ID.
+func matchesDevice(res DiscoveryResult, ref string) bool {
+	if res.DeviceID != "" && strings.EqualFold(res.DeviceID, ref) {
+		return true
+	}
+	if id, ok := strings.CutPrefix(ref, "console:"); ok {
+		return res.ConsoleID != "" && strings.EqualFold(res.ConsoleID, id)
+	}
+	if mac, err := net.ParseMAC(ref); err == nil {
+		return res.MAC == mac.String()
+	}
+	return false
+}
+
+// ResolveDevice finds the current IP address of a device by its MAC
+// address ("a4:cf:12:bd:3e:01"), device ID ("mac:a4cf12bd3e01") or
+// console ID ("console:554B1234567"). It runs a discovery pass with the
+// given options, plus WithBroadcast, and stops at the first match.
+// It returns ErrDeviceNotFound if the device does not show up before
+// discovery ends or the context is done.
+func ResolveDevice(ctx context.Context, ref string, opts ...DiscoverOption) (DiscoveryResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		found DiscoveryResult
+		ok    bool
+	)
+	opts = append([]DiscoverOption{WithBroadcast()}, opts...)
+	err := DiscoverEach(ctx, func(res DiscoveryResult) {
+		if !ok && matchesDevice(res, ref) {
+			found, ok = res, true
+			cancel()
+		}
+	}, opts...)
+	if ok {
+		return found, nil
+	}
+	if err != nil {
+		return DiscoveryResult{}, err
+	}
+	return DiscoveryResult{}, fmt.Errorf("%w: %s", ErrDeviceNotFound, ref)
+}
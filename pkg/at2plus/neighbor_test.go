@@ -0,0 +1,73 @@
+package at2plus
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const arpTable = `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.1      0x1         0x2         00:11:22:33:44:55     *        eth0
+192.168.1.50     0x1         0x2         A4:CF:12:BD:3E:01     *        wlan0
+192.168.1.77     0x1         0x0         00:00:00:00:00:00     *        wlan0
+192.168.1.78     0x1         0x2         00:00:00:00:00:00     *        wlan0
+`
+
+func TestParseARPTable(t *testing.T) {
+	table, err := parseARPTable(strings.NewReader(arpTable))
+	require.NoError(t, err)
+	assert.Len(t, table, 2)
+	assert.Equal(t, "a4:cf:12:bd:3e:01", table["192.168.1.50"].String())
+	assert.NotContains(t, table, "192.168.1.77")
+}
+
+func TestOUIVendor(t *testing.T) {
+	mac := func(s string) net.HardwareAddr {
+		m, err := net.ParseMAC(s)
+		require.NoError(t, err)
+		return m
+	}
+	assert.Equal(t, "Espressif", ouiVendor(mac("a4:cf:12:bd:3e:01")))
+	assert.Equal(t, "locally administered", ouiVendor(mac("da:a1:19:00:00:01")))
+	assert.Equal(t, "", ouiVendor(mac("00:11:22:33:44:55")))
+}
+
+func TestIdentify(t *testing.T) {
+	arpTablePath = filepath.Join(t.TempDir(), "arp")
+	t.Cleanup(func() { arpTablePath = "/proc/net/arp" })
+	require.NoError(t, os.WriteFile(arpTablePath, []byte(arpTable), 0o644))
+
+	var arp map[string]net.HardwareAddr
+	res := DiscoveryResult{IP: "192.168.1.50", ConsoleID: "C1"}
+	identify(&res, &arp)
+	assert.Equal(t, "a4:cf:12:bd:3e:01", res.MAC)
+	assert.Equal(t, "Espressif", res.Vendor)
+	assert.Equal(t, "mac:a4cf12bd3e01", res.DeviceID)
+
+	// Without a neighbour entry the console ID identifies the device
+	res = DiscoveryResult{IP: "192.168.1.60", ConsoleID: "C1"}
+	identify(&res, &arp)
+	assert.Empty(t, res.MAC)
+	assert.Equal(t, "console:C1", res.DeviceID)
+}
+
+func TestMatchesDevice(t *testing.T) {
+	res := DiscoveryResult{IP: "192.168.1.50", MAC: "a4:cf:12:bd:3e:01", ConsoleID: "554B1234567"}
+	res.DeviceID = deviceID(res)
+
+	for _, ref := range []string{"mac:a4cf12bd3e01", "mac:A4CF12BD3E01", "a4:cf:12:bd:3e:01", "A4-CF-12-BD-3E-01", "console:554b1234567"} {
+		assert.True(t, isDeviceRef(ref), ref)
+		assert.True(t, matchesDevice(res, ref), ref)
+	}
+	assert.False(t, matchesDevice(res, "mac:a4cf12bd3e02"))
+	assert.False(t, matchesDevice(res, "console:other"))
+
+	for _, ref := range []string{"192.168.1.50", "fe80::1", "airtouch.local"} {
+		assert.False(t, isDeviceRef(ref), ref)
+	}
+}
@@ -14,6 +14,15 @@ type clientConfig struct {
 	port           int
 	connectTimeout time.Duration
 	requestTimeout time.Duration
+	reconnect      bool
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	pendingPolicy  PendingPolicy
+	stateHandler   func(ConnState, error)
+	validation     ValidationMode
+	confirmTimeout time.Duration
+	maxInFlight    int
+	discoverOpts   []DiscoverOption
 	logger         *slog.Logger
 }
 
@@ -23,6 +32,10 @@ func defaultConfig() *clientConfig {
 		port:           9200,
 		connectTimeout: 5 * time.Second,
 		requestTimeout: 2 * time.Second,
+		minBackoff:     500 * time.Millisecond,
+		maxBackoff:     30 * time.Second,
+		pendingPolicy:  FailPending,
+		maxInFlight:    DefaultMaxInFlight,
 		logger:         nil,
 	}
 }
@@ -71,3 +84,98 @@ func WithLogger(logger *slog.Logger) ClientOption {
 		return nil
 	}
 }
+
+// WithReconnect enables reconnecting mode. When the connection drops the
+// client redials the device, waiting between attempts with exponential
+// backoff that starts at minBackoff and is capped at maxBackoff, plus jitter.
+// Without this option a dropped connection closes the client for good.
+func WithReconnect(minBackoff, maxBackoff time.Duration) ClientOption {
+	return func(c *clientConfig) error {
+		if minBackoff <= 0 {
+			return errors.New("min backoff must be positive")
+		}
+		if maxBackoff < minBackoff {
+			return errors.New("max backoff must not be less than min backoff")
+		}
+		c.reconnect = true
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+		return nil
+	}
+}
+
+// WithPendingPolicy sets what happens to in-flight requests when the
+// connection drops in reconnecting mode.
+// Default is FailPending.
+func WithPendingPolicy(p PendingPolicy) ClientOption {
+	return func(c *clientConfig) error {
+		if p != FailPending && p != ReplayPending {
+			return errors.New("unknown pending policy")
+		}
+		c.pendingPolicy = p
+		return nil
+	}
+}
+
+// WithStateHandler sets a function that is called on every connection state
+// transition, with the error that caused it if any. It is called from the
+// client's connection goroutine and must not block.
+func WithStateHandler(fn func(ConnState, error)) ClientOption {
+	return func(c *clientConfig) error {
+		c.stateHandler = fn
+		return nil
+	}
+}
+
+// WithValidation makes SetACControl check commands against the abilities
+// each AC reports (fetched once and cached) before sending them.
+// Default is ValidateOff.
+func WithValidation(mode ValidationMode) ClientOption {
+	return func(c *clientConfig) error {
+		if mode != ValidateOff && mode != ValidateReject && mode != ValidateClamp {
+			return errors.New("unknown validation mode")
+		}
+		c.validation = mode
+		return nil
+	}
+}
+
+// WithConfirm makes SetGroupControl and SetACControl wait until the device
+// reports the requested settings as applied, checking pushed status
+// updates and polling the status. If it does not within timeout, they
+// return a *NotAppliedError listing the settings that diverge. Relative
+// commands, such as GroupValueIncrease or ACPowerToggle, are not checked.
+// Default is off: commands succeed once the device acknowledges them.
+func WithConfirm(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) error {
+		if timeout <= 0 {
+			return errors.New("confirm timeout must be positive")
+		}
+		c.confirmTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxInFlight sets how many requests may await a response at once.
+// Further requests are queued, control commands ahead of queries.
+// Default is DefaultMaxInFlight.
+func WithMaxInFlight(n int) ClientOption {
+	return func(c *clientConfig) error {
+		if n < 1 || n > 256 {
+			return errors.New("max in-flight must be between 1 and 256")
+		}
+		c.maxInFlight = n
+		return nil
+	}
+}
+
+// WithDiscoverOptions configures the discovery pass that finds the device
+// when NewClient is given a MAC address or device ID instead of an IP,
+// e.g. to limit it to some interfaces. The pass also runs again when a
+// reconnecting client cannot reach the device at its last known address.
+func WithDiscoverOptions(opts ...DiscoverOption) ClientOption {
+	return func(c *clientConfig) error {
+		c.discoverOpts = append(c.discoverOpts, opts...)
+		return nil
+	}
+}
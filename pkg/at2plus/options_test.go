@@ -101,3 +101,61 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, 2*time.Second, cfg.requestTimeout)
 	assert.Nil(t, cfg.logger)
 }
+
+func TestWithReconnect(t *testing.T) {
+	cfg := defaultConfig()
+	assert.False(t, cfg.reconnect)
+
+	err := WithReconnect(time.Second, 10*time.Second)(cfg)
+	require.NoError(t, err)
+	assert.True(t, cfg.reconnect)
+	assert.Equal(t, time.Second, cfg.minBackoff)
+	assert.Equal(t, 10*time.Second, cfg.maxBackoff)
+}
+
+func TestWithReconnect_Invalid(t *testing.T) {
+	cfg := defaultConfig()
+
+	err := WithReconnect(0, time.Second)(cfg)
+	assert.Error(t, err)
+
+	err = WithReconnect(2*time.Second, time.Second)(cfg)
+	assert.Error(t, err)
+	assert.False(t, cfg.reconnect)
+}
+
+func TestWithPendingPolicy(t *testing.T) {
+	cfg := defaultConfig()
+	assert.Equal(t, FailPending, cfg.pendingPolicy)
+
+	err := WithPendingPolicy(ReplayPending)(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, ReplayPending, cfg.pendingPolicy)
+
+	err = WithPendingPolicy(PendingPolicy(7))(cfg)
+	assert.Error(t, err)
+}
+
+func TestWithValidation(t *testing.T) {
+	cfg := defaultConfig()
+	assert.Equal(t, ValidateOff, cfg.validation)
+
+	err := WithValidation(ValidateClamp)(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, ValidateClamp, cfg.validation)
+
+	err = WithValidation(ValidationMode(7))(cfg)
+	assert.Error(t, err)
+}
+
+func TestWithMaxInFlight(t *testing.T) {
+	cfg := defaultConfig()
+	assert.Equal(t, DefaultMaxInFlight, cfg.maxInFlight)
+
+	err := WithMaxInFlight(4)(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.maxInFlight)
+
+	assert.Error(t, WithMaxInFlight(0)(cfg))
+	assert.Error(t, WithMaxInFlight(257)(cfg))
+}
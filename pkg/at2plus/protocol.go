@@ -2,7 +2,6 @@ package at2plus
 
 import (
 	"encoding/binary"
-	"errors"
 	"fmt"
 )
 
@@ -38,11 +37,13 @@ const (
 	MaxDataLen = 1024
 )
 
+// Decoding errors. All of them match ErrProtocol.
 var (
-	ErrInvalidHeader   = errors.New("invalid header")
-	ErrInvalidChecksum = errors.New("invalid checksum")
-	ErrInvalidLength   = errors.New("invalid data length")
-	ErrDataLenExceeded = errors.New("data length exceeds maximum")
+	ErrInvalidHeader   error = protocolError("invalid header")
+	ErrInvalidChecksum error = protocolError("invalid checksum")
+	ErrInvalidLength   error = protocolError("invalid data length")
+	ErrDataLenExceeded error = protocolError("data length exceeds maximum")
+	ErrInvalidSubType  error = protocolError("invalid sub type")
 )
 
 // Packet represents a full AirTouch 2+ protocol packet
@@ -70,91 +71,70 @@ func NewPacket(address uint16, msgID uint8, msgType uint8, data []byte) *Packet
 
 // Encode serializes the packet into bytes
 func (p *Packet) Encode() []byte {
-	buf := make([]byte, 8+len(p.Data)+2) // Header(2)+Addr(2)+ID(1)+Type(1)+Len(2) + Data + CRC(2)
+	return p.AppendEncode(make([]byte, 0, 8+len(p.Data)+2)) // Header(2)+Addr(2)+ID(1)+Type(1)+Len(2) + Data + CRC(2)
+}
 
-	binary.BigEndian.PutUint16(buf[0:2], p.Header)
-	binary.BigEndian.PutUint16(buf[2:4], p.Address)
-	buf[4] = p.MsgID
-	buf[5] = p.MsgType
-	binary.BigEndian.PutUint16(buf[6:8], p.DataLen)
-	copy(buf[8:], p.Data)
+// AppendEncode appends the serialized packet to b and returns the extended
+// buffer. It does not allocate if b has enough spare capacity.
+func (p *Packet) AppendEncode(b []byte) []byte {
+	start := len(b)
+	b = binary.BigEndian.AppendUint16(b, p.Header)
+	b = binary.BigEndian.AppendUint16(b, p.Address)
+	b = append(b, p.MsgID, p.MsgType)
+	b = binary.BigEndian.AppendUint16(b, p.DataLen)
+	b = append(b, p.Data...)
 
 	// Calculate CRC on everything after header (Address onwards)
 	// Spec says: "Use all data except the header."
-	crcData := buf[2 : 8+len(p.Data)]
-	p.CRC = Checksum(crcData)
-
-	binary.BigEndian.PutUint16(buf[8+len(p.Data):], p.CRC)
+	p.CRC = Checksum(b[start+2:])
 
-	return buf
+	return binary.BigEndian.AppendUint16(b, p.CRC)
 }
 
 // Decode parses bytes into a Packet
 func Decode(data []byte) (*Packet, error) {
+	p := &Packet{}
+	if err := DecodeInto(p, data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeInto parses bytes into p, reusing the capacity of p.Data.
+// data is not retained, so the caller may reuse it afterwards.
+// On error p is left unchanged.
+func DecodeInto(p *Packet, data []byte) error {
 	if len(data) < 10 {
-		return nil, ErrInvalidLength
+		return ErrInvalidLength
 	}
 
 	header := binary.BigEndian.Uint16(data[0:2])
 	if header != HeaderBytes {
-		return nil, ErrInvalidHeader
+		return ErrInvalidHeader
 	}
 
-	address := binary.BigEndian.Uint16(data[2:4])
-	msgID := data[4]
-	msgType := data[5]
 	dataLen := binary.BigEndian.Uint16(data[6:8])
-
-	if len(data) < int(8+dataLen+2) {
-		return nil, ErrInvalidLength
+	n := int(dataLen)
+	if n > MaxDataLen || len(data) < 8+n+2 {
+		return ErrInvalidLength
 	}
 
-	packetData := make([]byte, dataLen)
-	copy(packetData, data[8:8+dataLen])
-
-	crcReceived := binary.BigEndian.Uint16(data[8+dataLen:])
+	crcReceived := binary.BigEndian.Uint16(data[8+n:])
 
 	// Validate CRC
-	crcData := data[2 : 8+dataLen]
+	crcData := data[2 : 8+n]
 	crcCalculated := Checksum(crcData)
 
 	if crcReceived != crcCalculated {
-		return nil, fmt.Errorf("%w: expected 0x%04X, got 0x%04X", ErrInvalidChecksum, crcCalculated, crcReceived)
+		return fmt.Errorf("%w: expected 0x%04X, got 0x%04X", ErrInvalidChecksum, crcCalculated, crcReceived)
 	}
 
-	return &Packet{
-		Header:  header,
-		Address: address,
-		MsgID:   msgID,
-		MsgType: msgType,
-		DataLen: dataLen,
-		Data:    packetData,
-		CRC:     crcReceived,
-	}, nil
-}
-
-// Checksum calculates the CRC16 Modbus checksum
-func Checksum(data []byte) uint16 {
-	crc := uint16(0xFFFF)
-	for _, b := range data {
-		crc ^= uint16(b)
-		for i := 0; i < 8; i++ {
-			if (crc & 0x0001) != 0 {
-				crc >>= 1
-				crc ^= 0xA001
-			} else {
-				crc >>= 1
-			}
-		}
-	}
-	// Note: The spec examples show Big Endian CRC in the packet (e.g. 0xFD 0x18).
-	// However, standard Modbus CRC is usually Little Endian.
-	// Let's check the spec example:
-	// Example: 0x55 0x55 0x80 0xB0 0x01 0xC0 0x00 0x0C ... CRC: 0x64 0xFD
-	// Wait, the example in spec says: 0x64 0xFD.
-	// Let's verify this with a test case before finalizing.
-	// But for now, I will return the uint16. The Encode/Decode functions use BigEndian to put/get it.
-	// If the calculation produces 0xFD64 but it's stored as 0x64FD, then we might need to swap.
-	// I'll assume standard calculation and BigEndian storage for now, and verify with test.
-	return crc
+	p.Header = header
+	p.Address = binary.BigEndian.Uint16(data[2:4])
+	p.MsgID = data[4]
+	p.MsgType = data[5]
+	p.DataLen = dataLen
+	p.Data = append(p.Data[:0], data[8:8+n]...)
+	p.CRC = crcReceived
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test cases from the Spec
@@ -20,8 +21,6 @@ func TestChecksum_SpecExample_TurnOffGroup2(t *testing.T) {
 	expectedCRC := uint16(0x64FD)
 
 	crc := Checksum(input)
-	// Note: If Checksum returns 0xFD64, then we need to handle endianness.
-	// Let's see what happens.
 	assert.Equal(t, expectedCRC, crc, "CRC should match spec example")
 }
 
@@ -79,6 +78,8 @@ func TestDecode_InvalidChecksum(t *testing.T) {
 	_, err := Decode(data)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "checksum")
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+	assert.ErrorIs(t, err, ErrProtocol)
 }
 
 func TestDecode_DataLengthMismatch(t *testing.T) {
@@ -90,3 +91,89 @@ func TestDecode_DataLengthMismatch(t *testing.T) {
 	_, err := Decode(data)
 	assert.Error(t, err)
 }
+
+func TestDecode_DataLengthOverflow(t *testing.T) {
+	// dataLen 0xFFFE would wrap 8+dataLen+2 around in 16 bits
+	data, _ := hex.DecodeString("55550072d5c0fffe00000000")
+
+	_, err := Decode(data)
+	assert.ErrorIs(t, err, ErrInvalidLength)
+}
+
+func TestEncode_SpecExample_RequestACError(t *testing.T) {
+	// Example from Spec Page 13: Request Error of AC 0
+	// 0x55 0x55 0x90 0xb0 0x01 0x1f 0x00 0x03 0xff 0x10 0x00 0x99 0x82
+
+	p := NewPacket(AddressSendExtended, 0x01, MsgTypeExtended, []byte{0xFF, ExtMsgTypeACError, 0x00})
+
+	assert.Equal(t, "555590b0011f0003ff10009982", hex.EncodeToString(p.Encode()))
+}
+
+func TestDecodeInto_ReusesData(t *testing.T) {
+	raw, _ := hex.DecodeString("5555B08001C00018210000000002000800000000000080004132000000000200832F")
+
+	p := &Packet{Data: make([]byte, 0, 64)}
+	backing := p.Data[:1]
+	require.NoError(t, DecodeInto(p, raw))
+	assert.Equal(t, uint8(1), p.MsgID)
+	assert.Len(t, p.Data, 24)
+	assert.Same(t, &backing[0], &p.Data[0], "data must reuse the existing buffer")
+
+	raw[10] = 0xEE // data is not retained
+	assert.Equal(t, byte(0x00), p.Data[2])
+}
+
+func TestAppendEncode(t *testing.T) {
+	data, _ := hex.DecodeString("200000000001000401020000")
+	p := NewPacket(AddressSendStandard, 1, MsgTypeControlStatus, data)
+
+	prefix := []byte{0xAA}
+	got := p.AppendEncode(prefix)
+	assert.Equal(t, byte(0xAA), got[0])
+	assert.Equal(t, p.Encode(), got[1:])
+}
+
+func BenchmarkEncode(b *testing.B) {
+	p := NewPacket(AddressSendStandard, 1, MsgTypeControlStatus, specGroupStatusData)
+	b.ReportAllocs()
+	for b.Loop() {
+		p.Encode()
+	}
+}
+
+func BenchmarkAppendEncode(b *testing.B) {
+	p := NewPacket(AddressSendStandard, 1, MsgTypeControlStatus, specGroupStatusData)
+	buf := make([]byte, 0, 64)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf = p.AppendEncode(buf[:0])
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Decode(specGroupStatusPacket); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeInto(b *testing.B) {
+	p := &Packet{}
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := DecodeInto(p, specGroupStatusPacket); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChecksum(b *testing.B) {
+	data := specGroupStatusPacket[2 : len(specGroupStatusPacket)-2]
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		Checksum(data)
+	}
+}
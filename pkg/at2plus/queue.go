@@ -0,0 +1,169 @@
+package at2plus
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultMaxInFlight is the number of requests sent to the device before
+// their responses arrive. The controller is a small embedded device, so by
+// default requests are strictly one at a time.
+const DefaultMaxInFlight = 1
+
+// priority orders queued requests. Control commands are sent before
+// status and extended queries, so a user action is never stuck behind
+// background polling.
+type priority int
+
+const (
+	priorityNormal priority = iota
+	priorityControl
+)
+
+// priorityOf returns the priority of a request payload.
+func priorityOf(msgType uint8, data []byte) priority {
+	if msgType == MsgTypeControlStatus && len(data) > 0 &&
+		(data[0] == SubMsgTypeGroupControl || data[0] == SubMsgTypeACControl) {
+		return priorityControl
+	}
+	return priorityNormal
+}
+
+// enqueue adds a request to the send queue and wakes the writer.
+func (c *Client) enqueue(req *pendingRequest) {
+	c.queueMu.Lock()
+	c.queue[req.priority] = append(c.queue[req.priority], req)
+	c.queueMu.Unlock()
+
+	select {
+	case c.queueCh <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue removes the next request to send, highest priority first.
+// It returns nil if the queue is empty.
+func (c *Client) dequeue() *pendingRequest {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	for p := len(c.queue) - 1; p >= 0; p-- {
+		if q := c.queue[p]; len(q) > 0 {
+			c.queue[p] = q[1:]
+			return q[0]
+		}
+	}
+	return nil
+}
+
+// queueLen returns the number of requests waiting to be sent.
+func (c *Client) queueLen() int {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	n := 0
+	for _, q := range c.queue {
+		n += len(q)
+	}
+	return n
+}
+
+// finish is called once the caller of a request stops waiting for it.
+// A request still in the queue is removed; one the writer already took
+// gives back its in-flight slot. If the request was sent but not answered,
+// its message ID is quarantined so that a late response is discarded.
+func (c *Client) finish(req *pendingRequest) {
+	c.pendingMu.Lock()
+	close(req.done)
+	if c.pending[req.msgID] == req {
+		delete(c.pending, req.msgID)
+		c.msgIDs.expire(req.msgID, time.Now())
+	} else {
+		c.msgIDs.release(req.msgID)
+	}
+	c.pendingMu.Unlock()
+
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	q := c.queue[req.priority]
+	for i, r := range q {
+		if r == req {
+			c.queue[req.priority] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+	<-c.slots
+}
+
+// writeLoop is the only goroutine that writes to the connection. It sends
+// queued requests while fewer than maxInFlight are awaiting a response,
+// and replays pending requests after a reconnect.
+func (c *Client) writeLoop() {
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-c.replayCh:
+			c.replayPending()
+			continue
+		case <-c.queueCh:
+		}
+
+		for c.queueLen() > 0 {
+			select {
+			case c.slots <- struct{}{}:
+			case <-c.replayCh:
+				c.replayPending()
+				continue
+			case <-c.closeCh:
+				return
+			}
+
+			req := c.dequeue()
+			if req == nil {
+				<-c.slots
+				break
+			}
+			c.transmit(req)
+		}
+	}
+}
+
+// transmit registers a dequeued request as pending and writes it.
+func (c *Client) transmit(req *pendingRequest) {
+	c.pendingMu.Lock()
+	select {
+	case <-req.done:
+		// The caller gave up between dequeue and now
+		c.pendingMu.Unlock()
+		return
+	default:
+	}
+	c.pending[req.msgID] = req
+	c.pendingMu.Unlock()
+
+	err := c.write(req.frame)
+	if err == nil {
+		if c.logger != nil {
+			c.logger.Debug("request sent", "msgID", req.msgID, "msgType", req.frame[5])
+		}
+		return
+	}
+
+	// In reconnecting mode with ReplayPending, a request that cannot be
+	// written now stays pending and is sent once the connection is back.
+	if c.reconnect && c.pendingPolicy == ReplayPending {
+		if c.logger != nil {
+			c.logger.Debug("request queued until reconnected", "msgID", req.msgID, "error", err)
+		}
+		return
+	}
+
+	if c.logger != nil {
+		c.logger.Error("failed to send request", "msgID", req.msgID, "error", err)
+	}
+	c.pendingMu.Lock()
+	if c.pending[req.msgID] == req {
+		delete(c.pending, req.msgID)
+		req.respCh <- response{err: fmt.Errorf("write request: %w", err)}
+	}
+	c.pendingMu.Unlock()
+}
@@ -0,0 +1,129 @@
+package at2plus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertNothingSent fails if the client writes anything within d.
+func assertNothingSent(t *testing.T, conn interface {
+	Read([]byte) (int, error)
+	SetReadDeadline(time.Time) error
+}, d time.Duration) {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(d)))
+	n, _ := conn.Read(make([]byte, 1))
+	require.NoError(t, conn.SetReadDeadline(time.Time{}))
+	assert.Zero(t, n, "unexpected request while another was in flight")
+}
+
+func TestQueue_MaxInFlight(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetGroupStatus(ctx)
+			assert.NoError(t, err)
+		}()
+	}
+
+	first := readRequest(t, conn)
+	assertNothingSent(t, conn, 50*time.Millisecond)
+	writeResponse(t, conn, first.MsgID, MsgTypeControlStatus, specGroupStatusData)
+
+	second := readRequest(t, conn)
+	assert.NotEqual(t, first.MsgID, second.MsgID)
+	writeResponse(t, conn, second.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	wg.Wait()
+}
+
+func TestQueue_ControlBeforeQueries(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	query := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetACStatus(ctx)
+			assert.NoError(t, err)
+		}()
+	}
+
+	// The first query occupies the only in-flight slot, the second waits
+	query()
+	blocking := readRequest(t, conn)
+	query()
+	require.Eventually(t, func() bool { return client.queueLen() == 1 }, time.Second, time.Millisecond)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := client.SetGroupControl(ctx, []GroupControl{{GroupNumber: 0, Power: Ptr(GroupPowerOn)}})
+		assert.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool { return client.queueLen() == 2 }, time.Second, time.Millisecond)
+
+	acStatus, err := MarshalACStatus([]ACStatus{{ACNumber: 0, Setpoint: Celsius(22)}})
+	require.NoError(t, err)
+	writeResponse(t, conn, blocking.MsgID, MsgTypeControlStatus, acStatus)
+
+	next := readRequest(t, conn)
+	assert.Equal(t, byte(SubMsgTypeGroupControl), next.Data[0])
+	writeResponse(t, conn, next.MsgID, MsgTypeControlStatus, specGroupStatusData)
+
+	last := readRequest(t, conn)
+	assert.Equal(t, byte(SubMsgTypeACStatus), last.Data[0])
+	writeResponse(t, conn, last.MsgID, MsgTypeControlStatus, acStatus)
+	wg.Wait()
+}
+
+func TestQueue_CanceledWhileQueued(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	go func() { _, _ = client.GetGroupStatus(context.Background()) }()
+	first := readRequest(t, conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.GetGroupStatus(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, client.queueLen())
+
+	// The canceled request is never sent
+	writeResponse(t, conn, first.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	assertNothingSent(t, conn, 50*time.Millisecond)
+}
+
+func TestQueue_ConcurrentWritesDoNotInterleave(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t, WithMaxInFlight(8))
+	const n = 50
+
+	go func() {
+		for range n {
+			req := readRequest(t, conn)
+			writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, specGroupStatusData)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetGroupStatus(context.Background())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,237 @@
+package at2plus
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// ConnState is the state of the connection to the device.
+type ConnState int
+
+const (
+	// StateConnecting means the client is dialing the device.
+	StateConnecting ConnState = iota
+	// StateConnected means the connection is up.
+	StateConnected
+	// StateDisconnected means the connection dropped. In reconnecting mode
+	// it is followed by StateConnecting.
+	StateDisconnected
+	// StateClosed means Close was called. No further transitions follow.
+	StateClosed
+)
+
+// String returns the lower-case name of the state.
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int(s))
+	}
+}
+
+// PendingPolicy decides what happens to in-flight requests when the
+// connection drops in reconnecting mode.
+type PendingPolicy int
+
+const (
+	// FailPending completes every in-flight request with ErrConnectionLost.
+	FailPending PendingPolicy = iota
+	// ReplayPending keeps in-flight requests and resends them once the
+	// connection is back. Each request is still bounded by its own timeout.
+	ReplayPending
+)
+
+// State returns the current connection state.
+func (c *Client) State() ConnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// setState records a state transition and reports it to the state handler.
+func (c *Client) setState(s ConnState, err error) {
+	c.mu.Lock()
+	if c.state == StateClosed {
+		c.mu.Unlock()
+		return
+	}
+	c.state = s
+	c.mu.Unlock()
+
+	if c.logger != nil {
+		c.logger.Debug("connection state changed", "addr", c.address(), "state", s, "error", err)
+	}
+	if c.stateHandler != nil {
+		c.stateHandler(s, err)
+	}
+}
+
+// disconnected tears down a failed connection and applies the pending policy.
+func (c *Client) disconnected(conn net.Conn, err error) {
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+	conn.Close()
+
+	c.setState(StateDisconnected, err)
+
+	if c.pendingPolicy == FailPending {
+		c.failPending(fmt.Errorf("%w: %w", ErrConnectionLost, err))
+	}
+}
+
+// failPending completes every in-flight request with err.
+func (c *Client) failPending(err error) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	for id, req := range c.pending {
+		req.respCh <- response{err: err}
+		delete(c.pending, id)
+	}
+}
+
+// redial reconnects with exponential backoff and jitter until it succeeds
+// or the client is closed, in which case it returns nil.
+func (c *Client) redial() net.Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.closeCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		c.setState(StateConnecting, nil)
+
+		addr := c.address()
+		dialCtx, dialCancel := context.WithTimeout(ctx, c.connectTimeout)
+		var d net.Dialer
+		conn, err := d.DialContext(dialCtx, "tcp", addr)
+		dialCancel()
+		if err == nil {
+			c.mu.Lock()
+			if c.isClosed {
+				c.mu.Unlock()
+				conn.Close()
+				return nil
+			}
+			c.conn = conn
+			c.mu.Unlock()
+
+			c.setState(StateConnected, nil)
+			if c.pendingPolicy == ReplayPending {
+				select {
+				case c.replayCh <- struct{}{}:
+				default:
+				}
+			}
+			return conn
+		}
+
+		c.setState(StateDisconnected, err)
+
+		// The device may have a new address, e.g. from DHCP
+		if c.device != "" && c.relocate(ctx, addr) {
+			continue
+		}
+
+		delay := backoff(attempt, c.minBackoff, c.maxBackoff)
+		if c.logger != nil {
+			c.logger.Warn("reconnect failed", "addr", addr, "attempt", attempt+1, "retryIn", delay, "error", err)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// relocate looks the device up on the network again and reports whether
+// it was found at an address other than addr, which it then switches to.
+func (c *Client) relocate(ctx context.Context, addr string) bool {
+	ctx, cancel := context.WithTimeout(ctx, c.connectTimeout)
+	defer cancel()
+	res, err := ResolveDevice(ctx, c.device, deviceDiscoverOptions(c.port, c.discoverOpts)...)
+	if err != nil {
+		return false
+	}
+	newAddr := net.JoinHostPort(res.IP, fmt.Sprint(c.port))
+	if newAddr == addr {
+		return false
+	}
+
+	c.mu.Lock()
+	c.addr = newAddr
+	c.mu.Unlock()
+	if c.logger != nil {
+		c.logger.Info("device moved", "device", c.device, "from", addr, "to", newAddr)
+	}
+	return true
+}
+
+// address returns the address the client connects to.
+func (c *Client) address() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addr
+}
+
+// deviceDiscoverOptions returns the options of the discovery pass that
+// resolves a device for a client connecting to port.
+func deviceDiscoverOptions(port int, opts []DiscoverOption) []DiscoverOption {
+	return append([]DiscoverOption{WithPorts(port)}, opts...)
+}
+
+// replayPending resends the frames of all in-flight requests.
+// It runs on the writer goroutine.
+func (c *Client) replayPending() {
+	c.pendingMu.Lock()
+	frames := make([][]byte, 0, len(c.pending))
+	for _, req := range c.pending {
+		frames = append(frames, req.frame)
+	}
+	c.pendingMu.Unlock()
+
+	for _, frame := range frames {
+		if err := c.write(frame); err != nil {
+			if c.logger != nil {
+				c.logger.Warn("failed to replay request", "msgID", frame[4], "error", err)
+			}
+			return
+		}
+	}
+}
+
+// backoff returns the delay before reconnect attempt n (zero based):
+// lo doubled n times, capped at hi, with up to half of it randomised
+// so that many clients do not redial in lockstep.
+func backoff(n int, lo, hi time.Duration) time.Duration {
+	d := lo
+	for i := 0; i < n && d < hi; i++ {
+		d *= 2
+	}
+	if d > hi {
+		d = hi
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,134 @@
+package at2plus
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stateRecorder collects connection state transitions.
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []ConnState
+}
+
+func (r *stateRecorder) handle(s ConnState, _ error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, s)
+}
+
+func (r *stateRecorder) get() []ConnState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ConnState(nil), r.states...)
+}
+
+func TestReconnect_RedialsAfterDrop(t *testing.T) {
+	dev := newFakeDevice(t)
+	rec := &stateRecorder{}
+	client, conn := dev.dial(t,
+		WithReconnect(10*time.Millisecond, 50*time.Millisecond),
+		WithStateHandler(rec.handle),
+	)
+
+	conn.Close()
+	conn2 := dev.accept(t)
+
+	require.Eventually(t, func() bool { return client.State() == StateConnected }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []ConnState{StateConnected, StateDisconnected, StateConnecting, StateConnected}, rec.get())
+
+	go func() {
+		req := readRequest(t, conn2)
+		writeResponse(t, conn2, req.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	}()
+	groups, err := client.GetGroupStatus(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+}
+
+func TestReconnect_FailPending(t *testing.T) {
+	dev := newFakeDevice(t)
+	client, conn := dev.dial(t, WithReconnect(10*time.Millisecond, 50*time.Millisecond))
+
+	go func() {
+		readRequest(t, conn)
+		conn.Close()
+	}()
+
+	_, err := client.GetGroupStatus(context.Background())
+	assert.ErrorIs(t, err, ErrConnectionLost)
+}
+
+func TestReconnect_ReplayPending(t *testing.T) {
+	dev := newFakeDevice(t)
+	client, conn := dev.dial(t,
+		WithReconnect(10*time.Millisecond, 50*time.Millisecond),
+		WithPendingPolicy(ReplayPending),
+		WithRequestTimeout(2*time.Second),
+	)
+
+	go func() {
+		first := readRequest(t, conn)
+		conn.Close()
+
+		conn2 := dev.accept(t)
+		replayed := readRequest(t, conn2)
+		assert.Equal(t, first.MsgID, replayed.MsgID)
+		writeResponse(t, conn2, replayed.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	}()
+
+	groups, err := client.GetGroupStatus(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, groups, 2)
+}
+
+func TestReconnect_CloseStopsRedial(t *testing.T) {
+	dev := newFakeDevice(t)
+	rec := &stateRecorder{}
+	client, conn := dev.dial(t,
+		WithReconnect(10*time.Millisecond, 20*time.Millisecond),
+		WithStateHandler(rec.handle),
+	)
+
+	dev.ln.Close()
+	conn.Close()
+	require.Eventually(t, func() bool { return client.State() == StateDisconnected }, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, client.Close())
+	assert.Equal(t, StateClosed, client.State())
+	states := rec.get()
+	assert.Equal(t, StateClosed, states[len(states)-1])
+}
+
+func TestWithoutReconnect_DropClosesClient(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	conn.Close()
+	require.Eventually(t, func() bool { return client.State() == StateClosed }, time.Second, 5*time.Millisecond)
+}
+
+func TestBackoff(t *testing.T) {
+	lo, hi := 100*time.Millisecond, time.Second
+
+	for n, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			d := backoff(n, lo, hi)
+			assert.GreaterOrEqual(t, d, want/2, "attempt %d", n)
+			assert.LessOrEqual(t, d, want, "attempt %d", n)
+		}
+	}
+}
+
+func TestConnState_String(t *testing.T) {
+	assert.Equal(t, "connecting", StateConnecting.String())
+	assert.Equal(t, "connected", StateConnected.String())
+	assert.Equal(t, "disconnected", StateDisconnected.String())
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "ConnState(9)", ConnState(9).String())
+}
@@ -0,0 +1,490 @@
+package at2plus
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// GroupState is the state of a group as kept by a StateStore.
+type GroupState struct {
+	GroupStatus
+	// Name is the name shown on the console.
+	Name string
+	// AC is the number of the AC serving the group, from the StartGroup
+	// and GroupCount of the ACs' abilities, or nil if none does.
+	AC *uint8
+	// Since holds the time each field took its current value, keyed by
+	// field name: "Power", "Percent", "TurboSupport", "Spill" and "Name".
+	Since map[string]time.Time
+}
+
+// ACState is the state of an AC as kept by a StateStore.
+type ACState struct {
+	ACStatus
+	// Ability is what the AC supports, including its name.
+	Ability ACAbility
+	// Groups are the numbers of the groups the AC serves.
+	Groups []uint8
+	// ErrorMessage is the error information from GetACError while
+	// ErrorCode is not zero, if it could be fetched.
+	ErrorMessage string
+	// Since holds the time each field took its current value, keyed by
+	// field name: "Power", "Mode", "FanSpeed", "Setpoint", "Temperature",
+	// "Turbo", "Bypass", "Spill", "Timer" and "ErrorCode".
+	Since map[string]time.Time
+}
+
+// Snapshot is the state of the whole system at one point in time.
+type Snapshot struct {
+	// Groups are ordered by group number.
+	Groups []GroupState
+	// ACs are ordered by AC number.
+	ACs []ACState
+	// Updated is when status was last received.
+	Updated time.Time
+}
+
+// Group returns the state of group n.
+func (s Snapshot) Group(n uint8) (GroupState, bool) {
+	i := slices.IndexFunc(s.Groups, func(g GroupState) bool { return g.GroupNumber == n })
+	if i < 0 {
+		return GroupState{}, false
+	}
+	return s.Groups[i], true
+}
+
+// AC returns the state of AC n.
+func (s Snapshot) AC(n uint8) (ACState, bool) {
+	i := slices.IndexFunc(s.ACs, func(ac ACState) bool { return ac.ACNumber == n })
+	if i < 0 {
+		return ACState{}, false
+	}
+	return s.ACs[i], true
+}
+
+// clone returns a deep copy of s.
+func (s Snapshot) clone() Snapshot {
+	c := Snapshot{
+		Groups:  slices.Clone(s.Groups),
+		ACs:     slices.Clone(s.ACs),
+		Updated: s.Updated,
+	}
+	for i := range c.Groups {
+		c.Groups[i].Since = maps.Clone(c.Groups[i].Since)
+		if ac := c.Groups[i].AC; ac != nil {
+			c.Groups[i].AC = Ptr(*ac)
+		}
+	}
+	for i := range c.ACs {
+		c.ACs[i].Since = maps.Clone(c.ACs[i].Since)
+		c.ACs[i].Groups = slices.Clone(c.ACs[i].Groups)
+	}
+	return c
+}
+
+// group returns the state of group n, adding it if it is new.
+func (s *Snapshot) group(n uint8) *GroupState {
+	i := slices.IndexFunc(s.Groups, func(g GroupState) bool { return g.GroupNumber == n })
+	if i < 0 {
+		s.Groups = append(s.Groups, GroupState{GroupStatus: GroupStatus{GroupNumber: n}, Since: make(map[string]time.Time)})
+		slices.SortFunc(s.Groups, func(a, b GroupState) int { return int(a.GroupNumber) - int(b.GroupNumber) })
+		i = slices.IndexFunc(s.Groups, func(g GroupState) bool { return g.GroupNumber == n })
+	}
+	return &s.Groups[i]
+}
+
+// ac returns the state of AC n, adding it if it is new.
+func (s *Snapshot) ac(n uint8) *ACState {
+	i := slices.IndexFunc(s.ACs, func(ac ACState) bool { return ac.ACNumber == n })
+	if i < 0 {
+		s.ACs = append(s.ACs, ACState{ACStatus: ACStatus{ACNumber: n}, Since: make(map[string]time.Time)})
+		slices.SortFunc(s.ACs, func(a, b ACState) int { return int(a.ACNumber) - int(b.ACNumber) })
+		i = slices.IndexFunc(s.ACs, func(ac ACState) bool { return ac.ACNumber == n })
+	}
+	return &s.ACs[i]
+}
+
+// link fills in which AC serves which groups.
+func (s *Snapshot) link() {
+	for i := range s.Groups {
+		s.Groups[i].AC = nil
+	}
+	for i := range s.ACs {
+		ac := &s.ACs[i]
+		ac.Groups = nil
+		for n := int(ac.Ability.StartGroup); n < int(ac.Ability.StartGroup)+int(ac.Ability.GroupCount); n++ {
+			ac.Groups = append(ac.Groups, uint8(n))
+			if g := slices.IndexFunc(s.Groups, func(g GroupState) bool { return int(g.GroupNumber) == n }); g >= 0 {
+				s.Groups[g].AC = Ptr(ac.ACNumber)
+			}
+		}
+	}
+}
+
+// diffSnapshots returns the changes from old to cur and records in the
+// Since maps of cur when the changed fields took their values. Groups and
+// ACs that are new in cur have every field stamped and raise no events.
+func diffSnapshots(old, cur *Snapshot, now time.Time) []Event {
+	var events []Event
+	at := EventTime{Time: now}
+
+	for i := range cur.Groups {
+		g := &cur.Groups[i]
+		prev, ok := old.Group(g.GroupNumber)
+		changed := func(field string, differ bool) bool {
+			if differ || !ok {
+				g.Since[field] = now
+			}
+			return differ && ok
+		}
+		if changed("Power", prev.Power != g.Power) {
+			events = append(events, GroupPowerChanged{at, g.GroupNumber, prev.Power, g.Power})
+		}
+		if changed("Percent", prev.Percent != g.Percent) {
+			events = append(events, GroupPercentChanged{at, g.GroupNumber, prev.Percent, g.Percent})
+		}
+		changed("TurboSupport", prev.TurboSupport != g.TurboSupport)
+		if changed("Spill", prev.Spill != g.Spill) {
+			events = append(events, GroupSpillChanged{at, g.GroupNumber, prev.Spill, g.Spill})
+		}
+		if changed("Name", prev.Name != g.Name) {
+			events = append(events, GroupNameChanged{at, g.GroupNumber, prev.Name, g.Name})
+		}
+	}
+
+	for i := range cur.ACs {
+		ac := &cur.ACs[i]
+		prev, ok := old.AC(ac.ACNumber)
+		changed := func(field string, differ bool) bool {
+			if differ || !ok {
+				ac.Since[field] = now
+			}
+			return differ && ok
+		}
+		if changed("Power", prev.Power != ac.Power) {
+			events = append(events, ACPowerChanged{at, ac.ACNumber, prev.Power, ac.Power})
+		}
+		if changed("Mode", prev.Mode != ac.Mode) {
+			events = append(events, ACModeChanged{at, ac.ACNumber, prev.Mode, ac.Mode})
+		}
+		if changed("FanSpeed", prev.FanSpeed != ac.FanSpeed) {
+			events = append(events, ACFanSpeedChanged{at, ac.ACNumber, prev.FanSpeed, ac.FanSpeed})
+		}
+		if changed("Setpoint", prev.Setpoint != ac.Setpoint) {
+			events = append(events, ACSetpointChanged{at, ac.ACNumber, prev.Setpoint, ac.Setpoint})
+		}
+		if changed("Temperature", prev.Temperature != ac.Temperature) {
+			events = append(events, ACTemperatureChanged{at, ac.ACNumber, prev.Temperature, ac.Temperature})
+		}
+		changed("Turbo", prev.Turbo != ac.Turbo)
+		changed("Bypass", prev.Bypass != ac.Bypass)
+		changed("Spill", prev.Spill != ac.Spill)
+		changed("Timer", prev.Timer != ac.Timer)
+		if changed("ErrorCode", prev.ErrorCode != ac.ErrorCode) {
+			if ac.ErrorCode != 0 {
+				events = append(events, ACErrorRaised{at, ac.ACNumber, ac.ErrorCode, ac.ErrorMessage})
+			} else {
+				events = append(events, ACErrorCleared{at, ac.ACNumber, prev.ErrorCode})
+			}
+		}
+	}
+	return events
+}
+
+// errorLookupTimeout bounds the error information lookups made for a
+// pushed update. Pushes are applied in order, so a lookup holds up those
+// behind it.
+var errorLookupTimeout = 500 * time.Millisecond
+
+// StateStore keeps the latest view of the whole system: group status with
+// the group names, and AC status with the AC abilities and the groups each
+// AC serves. It is loaded when created, then kept current from the status
+// updates the device pushes, and emits an Event for each change it sees.
+//
+// Pushed updates only carry status. Call Refresh to pick up renamed
+// groups, or to catch up after a reconnecting client was disconnected.
+// When a push reports a new AC error code, the store fetches the error
+// information before applying it, which delays that push by up to half a
+// second; ACErrorRaised has no Message if the fetch did not complete.
+//
+// A StateStore is safe for concurrent use.
+type StateStore struct {
+	client *Client
+	sub    *Subscription
+	ctx    context.Context // canceled by Close
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	snap     Snapshot
+	watchers map[chan Event]struct{}
+	closed   bool
+
+	// pushes counts the pushed updates applied; groupPushed and acPushed
+	// hold the count as of the last push for each group and AC, so Refresh
+	// does not overwrite them with status polled before.
+	pushes      uint64
+	groupPushed map[uint8]uint64
+	acPushed    map[uint8]uint64
+}
+
+// NewStateStore loads the state of the system and keeps it current until
+// Close is called or the client is closed.
+func NewStateStore(ctx context.Context, c *Client) (*StateStore, error) {
+	s, err := startStateStore(c)
+	if err != nil {
+		return nil, fmt.Errorf("new state store: %w", err)
+	}
+	if err := s.Refresh(ctx); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("new state store: %w", err)
+	}
+	return s, nil
+}
+
+// startStateStore returns an empty store applying the updates pushed to c.
+func startStateStore(c *Client) (*StateStore, error) {
+	// Subscribe first, so no change is missed between loading and watching
+	sub, err := c.Subscribe(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StateStore{
+		client:      c,
+		sub:         sub,
+		done:        make(chan struct{}),
+		watchers:    make(map[chan Event]struct{}),
+		groupPushed: make(map[uint8]uint64),
+		acPushed:    make(map[uint8]uint64),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s, nil
+}
+
+// run applies pushed status updates until the subscription ends.
+func (s *StateStore) run() {
+	defer s.shutdown()
+	for u := range s.sub.Updates() {
+		if u.Groups == nil && u.ACs == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, errorLookupTimeout)
+		msgs := s.errorMessages(ctx, u.ACs)
+		cancel()
+		s.update(func(next *Snapshot) {
+			s.pushes++
+			for _, g := range u.Groups {
+				s.groupPushed[g.GroupNumber] = s.pushes
+			}
+			for _, ac := range u.ACs {
+				s.acPushed[ac.ACNumber] = s.pushes
+			}
+			mergeStatus(next, u.Groups, u.ACs, nil, msgs)
+		})
+	}
+}
+
+// Refresh queries the status, group names and AC abilities from the
+// device and applies them, emitting events for whatever changed. Status
+// pushed while Refresh polls is newer than what it polled and is kept.
+func (s *StateStore) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	polled := s.pushes
+	s.mu.Unlock()
+
+	groups, err := s.client.GetGroupStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	acs, err := s.client.GetACStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	names, err := s.client.GetGroupNames(ctx)
+	if err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	abilities := make(map[uint8]ACAbility, len(acs))
+	for _, ac := range acs {
+		ab, err := s.client.ACAbilityCached(ctx, ac.ACNumber)
+		if err != nil {
+			return fmt.Errorf("refresh: %w", err)
+		}
+		abilities[ac.ACNumber] = ab
+	}
+	msgs := s.errorMessages(ctx, acs)
+
+	s.update(func(next *Snapshot) {
+		groups := slices.DeleteFunc(groups, func(g GroupStatus) bool { return s.groupPushed[g.GroupNumber] > polled })
+		acs := slices.DeleteFunc(acs, func(ac ACStatus) bool { return s.acPushed[ac.ACNumber] > polled })
+		mergeStatus(next, groups, acs, abilities, msgs)
+		for n, ab := range abilities {
+			// Also for ACs whose polled status was dropped
+			if i := slices.IndexFunc(next.ACs, func(ac ACState) bool { return ac.ACNumber == n }); i >= 0 {
+				next.ACs[i].Ability = ab
+			}
+		}
+		for _, n := range names {
+			if i := slices.IndexFunc(next.Groups, func(g GroupState) bool { return g.GroupNumber == n.GroupNumber }); i >= 0 {
+				next.Groups[i].Name = n.Name
+			}
+		}
+	})
+	return nil
+}
+
+// mergeStatus applies status, abilities and error messages to a snapshot.
+func mergeStatus(next *Snapshot, groups []GroupStatus, acs []ACStatus, abilities map[uint8]ACAbility, msgs map[uint8]string) {
+	for _, g := range groups {
+		next.group(g.GroupNumber).GroupStatus = g
+	}
+	for _, st := range acs {
+		ac := next.ac(st.ACNumber)
+		prevCode := ac.ErrorCode
+		ac.ACStatus = st
+		if ab, ok := abilities[st.ACNumber]; ok {
+			ac.Ability = ab
+		}
+		if msg, ok := msgs[st.ACNumber]; ok {
+			ac.ErrorMessage = msg
+		} else if st.ErrorCode != prevCode {
+			// The message is of the previous code
+			ac.ErrorMessage = ""
+		}
+		if st.ErrorCode == 0 {
+			ac.ErrorMessage = ""
+		}
+	}
+}
+
+// errorMessages fetches the error information of the ACs that report an
+// error code the store does not have a message for. ACs whose information
+// cannot be fetched are left out.
+func (s *StateStore) errorMessages(ctx context.Context, acs []ACStatus) map[uint8]string {
+	s.mu.Lock()
+	var fetch []uint8
+	for _, st := range acs {
+		if st.ErrorCode == 0 {
+			continue
+		}
+		if prev, ok := s.snap.AC(st.ACNumber); !ok || prev.ErrorCode != st.ErrorCode || prev.ErrorMessage == "" {
+			fetch = append(fetch, st.ACNumber)
+		}
+	}
+	s.mu.Unlock()
+
+	msgs := make(map[uint8]string)
+	for _, n := range fetch {
+		acErr, err := s.client.GetACError(ctx, n)
+		if err != nil {
+			if s.client.logger != nil {
+				s.client.logger.Debug("failed to get AC error", "ac", n, "error", err)
+			}
+			continue
+		}
+		msgs[n] = acErr.Message
+	}
+	return msgs
+}
+
+// update applies fn to a copy of the snapshot, replaces the snapshot with
+// it and emits the changes.
+func (s *StateStore) update(fn func(next *Snapshot)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	next := s.snap.clone()
+	fn(&next)
+	next.link()
+	next.Updated = now
+	events := diffSnapshots(&s.snap, &next, now)
+	s.snap = next
+
+	for ch := range s.watchers {
+		for _, ev := range events {
+			deliverEvent(ch, ev)
+		}
+	}
+}
+
+// Snapshot returns a copy of the current state.
+func (s *StateStore) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snap.clone()
+}
+
+// Watch returns a channel that receives the changes the store sees from
+// now on. It buffers DefaultSubscriptionBuffer events; when a slow reader
+// lets it fill up, the oldest are dropped. The channel is closed when ctx
+// is done or the store is closed.
+func (s *StateStore) Watch(ctx context.Context) <-chan Event {
+	ch := make(chan Event, DefaultSubscriptionBuffer)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		close(ch)
+		return ch
+	}
+	s.watchers[ch] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.watchers[ch]; ok {
+			delete(s.watchers, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// deliverEvent hands an event to a watcher without blocking, dropping the
+// oldest buffered event if the buffer is full.
+func deliverEvent(ch chan Event, ev Event) {
+	for {
+		select {
+		case ch <- ev:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Close stops keeping the state current and closes every Watch channel.
+// The client is left open.
+func (s *StateStore) Close() {
+	s.cancel()
+	s.sub.Close()
+	<-s.done
+}
+
+// shutdown closes the watchers once the subscription has ended.
+func (s *StateStore) shutdown() {
+	s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for ch := range s.watchers {
+		delete(s.watchers, ch)
+		close(ch)
+	}
+	close(s.done)
+}
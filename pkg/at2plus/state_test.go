@@ -0,0 +1,232 @@
+package at2plus
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+
+	var empty, old Snapshot
+	mergeStatus(&old,
+		[]GroupStatus{{GroupNumber: 0, Power: GroupPowerStateOn, Percent: 50}, {GroupNumber: 1}},
+		[]ACStatus{{ACNumber: 0, Power: ACPowerStateOn, Mode: ACModeCool, Setpoint: Celsius(24), Temperature: Celsius(26)}},
+		map[uint8]ACAbility{0: {StartGroup: 0, GroupCount: 2}}, nil)
+	old.link()
+
+	// Everything is new: stamped, but no events
+	assert.Empty(t, diffSnapshots(&empty, &old, t0))
+	assert.Equal(t, t0, old.Groups[1].Since["Percent"])
+	assert.Equal(t, t0, old.ACs[0].Since["ErrorCode"])
+	assert.Equal(t, []uint8{0, 1}, old.ACs[0].Groups)
+	require.NotNil(t, old.Groups[1].AC)
+	assert.Equal(t, uint8(0), *old.Groups[1].AC)
+
+	cur := old.clone()
+	cur.Groups[0].Power = GroupPowerStateOff
+	cur.Groups[1].Name = "Kitchen"
+	cur.ACs[0].Setpoint = Celsius(22)
+	cur.ACs[0].ErrorCode = 0xFFFE
+	cur.ACs[0].ErrorMessage = "ER: FFFE"
+
+	at := EventTime{Time: t1}
+	events := diffSnapshots(&old, &cur, t1)
+	assert.Equal(t, []Event{
+		GroupPowerChanged{at, 0, GroupPowerStateOn, GroupPowerStateOff},
+		GroupNameChanged{at, 1, "", "Kitchen"},
+		ACSetpointChanged{at, 0, Celsius(24), Celsius(22)},
+		ACErrorRaised{at, 0, 0xFFFE, "ER: FFFE"},
+	}, events)
+	assert.Equal(t, t1, cur.Groups[0].Since["Power"])
+	assert.Equal(t, t0, cur.Groups[0].Since["Percent"])
+	assert.Equal(t, t0, old.Groups[0].Since["Power"], "clone shares Since")
+
+	next := cur.clone()
+	next.ACs[0].ErrorCode = 0
+	assert.Equal(t, []Event{ACErrorCleared{EventTime{t1}, 0, 0xFFFE}}, diffSnapshots(&cur, &next, t1))
+}
+
+// newTestStore starts a store on a fake device, skipping the initial
+// Refresh.
+func newTestStore(t *testing.T) (*StateStore, *Client, net.Conn) {
+	t.Helper()
+	client, conn := newFakeDevice(t).dial(t)
+	s, err := startStateStore(client)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s, client, conn
+}
+
+func receiveEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		require.True(t, ok, "events channel closed")
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestStateStore_WatchDropsOldest(t *testing.T) {
+	s, _, _ := newTestStore(t)
+	events := s.Watch(context.Background())
+
+	// The first update adds the group; each later one changes its percent
+	n := DefaultSubscriptionBuffer + 2
+	for pct := 0; pct <= n; pct++ {
+		s.update(func(next *Snapshot) {
+			mergeStatus(next, []GroupStatus{{GroupNumber: 0, Percent: pct}}, nil, nil, nil)
+		})
+	}
+
+	require.Len(t, events, DefaultSubscriptionBuffer)
+	for pct := n - DefaultSubscriptionBuffer + 1; pct <= n; pct++ {
+		ev, ok := receiveEvent(t, events).(GroupPercentChanged)
+		require.True(t, ok)
+		assert.Equal(t, pct, ev.New)
+	}
+}
+
+func TestStateStore_CloseClosesWatchers(t *testing.T) {
+	s, _, _ := newTestStore(t)
+	w1 := s.Watch(context.Background())
+	w2 := s.Watch(context.Background())
+	s.Close()
+
+	for _, w := range []<-chan Event{w1, w2} {
+		_, ok := <-w
+		assert.False(t, ok)
+	}
+	_, ok := <-s.Watch(context.Background())
+	assert.False(t, ok, "Watch after Close")
+}
+
+func TestStateStore_ClientCloseClosesWatchers(t *testing.T) {
+	s, client, _ := newTestStore(t)
+	events := s.Watch(context.Background())
+	require.NoError(t, client.Close())
+
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("events channel not closed after client close")
+	}
+}
+
+func TestStateStore_WatchContextCancel(t *testing.T) {
+	s, _, _ := newTestStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	events := s.Watch(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("events channel not closed after context cancel")
+	}
+}
+
+func TestStateStore_ErrorLookupBounded(t *testing.T) {
+	defer func(d time.Duration) { errorLookupTimeout = d }(errorLookupTimeout)
+	errorLookupTimeout = 50 * time.Millisecond
+
+	// The device never answers the lookup; the client would wait its
+	// request timeout of a second
+	s, _, conn := newTestStore(t)
+	events := s.Watch(context.Background())
+	data, err := MarshalACStatus([]ACStatus{{ACNumber: 0, Setpoint: Celsius(21), ErrorCode: 0xFFFE}})
+	require.NoError(t, err)
+	writeResponse(t, conn, 0, MsgTypeControlStatus, data)
+	require.Eventually(t, func() bool { _, ok := s.Snapshot().AC(0); return ok }, 500*time.Millisecond, 5*time.Millisecond)
+
+	data, err = MarshalACStatus([]ACStatus{{ACNumber: 0, Setpoint: Celsius(22), ErrorCode: 0xFFFE}})
+	require.NoError(t, err)
+	start := time.Now()
+	writeResponse(t, conn, 0, MsgTypeControlStatus, data)
+	_, ok := receiveEvent(t, events).(ACSetpointChanged)
+	require.True(t, ok)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestStateStore_PushedErrorMessage(t *testing.T) {
+	s, _, conn := newTestStore(t)
+	events := s.Watch(context.Background())
+
+	// Lookups are answered in turn; an invalid answer fails the lookup
+	var lookups atomic.Int32
+	answers := []string{"ER: FFFE", "", "ER: FFFD"}
+	go func() {
+		framer := NewFramer(conn)
+		for {
+			req, err := framer.Next()
+			if err != nil {
+				return
+			}
+			if req.MsgType == MsgTypeExtended && req.Data[1] == ExtMsgTypeACError {
+				data := []byte{0xFF, ExtMsgTypeACError}
+				if n := int(lookups.Add(1)); n <= len(answers) && answers[n-1] != "" {
+					data, _ = MarshalACError(ACError{ACNumber: 0, Message: answers[n-1]})
+				}
+				conn.Write(NewPacket(AddressRecvExtended, req.MsgID, MsgTypeExtended, data).Encode())
+			}
+		}
+	}()
+	push := func(st ACStatus) {
+		data, err := MarshalACStatus([]ACStatus{st})
+		require.NoError(t, err)
+		writeResponse(t, conn, 0, MsgTypeControlStatus, data)
+	}
+
+	st := ACStatus{ACNumber: 0, Setpoint: Celsius(21), Temperature: Celsius(22)}
+	push(st)
+	require.Eventually(t, func() bool { _, ok := s.Snapshot().AC(0); return ok }, time.Second, 5*time.Millisecond)
+
+	st.ErrorCode = 0xFFFE
+	push(st)
+	raised, ok := receiveEvent(t, events).(ACErrorRaised)
+	require.True(t, ok)
+	assert.Equal(t, "ER: FFFE", raised.Message)
+
+	// The message is known; a push with the same code looks nothing up
+	st.Temperature = Celsius(23)
+	push(st)
+	_, ok = receiveEvent(t, events).(ACTemperatureChanged)
+	require.True(t, ok)
+	assert.Equal(t, int32(1), lookups.Load())
+
+	// A new code whose lookup fails does not keep the old message, and
+	// the message is looked up again on the next push
+	st.ErrorCode = 0xFFFD
+	push(st)
+	raised, ok = receiveEvent(t, events).(ACErrorRaised)
+	require.True(t, ok)
+	assert.Equal(t, 0xFFFD, raised.Code)
+	assert.Empty(t, raised.Message)
+	ac, _ := s.Snapshot().AC(0)
+	assert.Empty(t, ac.ErrorMessage)
+
+	push(st)
+	require.Eventually(t, func() bool {
+		ac, _ := s.Snapshot().AC(0)
+		return ac.ErrorMessage == "ER: FFFD"
+	}, time.Second, 5*time.Millisecond)
+
+	st.ErrorCode = 0
+	push(st)
+	_, ok = receiveEvent(t, events).(ACErrorCleared)
+	require.True(t, ok)
+	ac, _ = s.Snapshot().AC(0)
+	assert.Empty(t, ac.ErrorMessage)
+}
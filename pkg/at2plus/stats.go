@@ -0,0 +1,31 @@
+package at2plus
+
+import "sync/atomic"
+
+// Stats are counters of noteworthy events on a client's connections.
+type Stats struct {
+	// LateResponses counts responses that arrived after their request
+	// timed out. They are discarded rather than delivered to a newer
+	// request.
+	LateResponses uint64
+	// DroppedBytes counts received bytes that were not part of a valid
+	// packet and were skipped to resynchronise.
+	DroppedBytes uint64
+	// BadChecksums counts received packets rejected because of their CRC.
+	BadChecksums uint64
+}
+
+// clientStats holds the live counters behind Stats.
+type clientStats struct {
+	lateResponses atomic.Uint64
+}
+
+// Stats returns a snapshot of the client's counters.
+func (c *Client) Stats() Stats {
+	fs := c.framer.Stats()
+	return Stats{
+		LateResponses: c.stats.lateResponses.Load(),
+		DroppedBytes:  fs.DroppedBytes,
+		BadChecksums:  fs.BadChecksums,
+	}
+}
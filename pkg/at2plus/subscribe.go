@@ -0,0 +1,209 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSubscriptionBuffer is the number of updates a Subscription buffers
+// before its overflow policy is applied.
+const DefaultSubscriptionBuffer = 16
+
+// Update is a message the device sent on its own, without being asked.
+// The AirTouch 2+ pushes group and AC status messages whenever their state
+// changes (for example from the wall console or the app).
+type Update struct {
+	// Packet is the raw packet as received from the device.
+	Packet *Packet
+	// Groups is set when the packet is a group status message (0x21).
+	Groups []GroupStatus
+	// ACs is set when the packet is an AC status message (0x23).
+	ACs []ACStatus
+}
+
+// OverflowPolicy decides what happens when a subscriber's buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest buffered update to make room for the
+	// new one, so a slow consumer always sees the most recent state.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the incoming update and keeps the buffer as is.
+	DropNewest
+)
+
+// SubscribeOption configures a Subscription.
+type SubscribeOption func(*subscribeConfig) error
+
+// subscribeConfig holds the configuration for a Subscription.
+type subscribeConfig struct {
+	bufferSize int
+	policy     OverflowPolicy
+}
+
+// WithBufferSize sets how many updates are buffered for the subscriber.
+// Default is DefaultSubscriptionBuffer.
+func WithBufferSize(n int) SubscribeOption {
+	return func(c *subscribeConfig) error {
+		if n < 1 {
+			return errors.New("buffer size must be at least 1")
+		}
+		c.bufferSize = n
+		return nil
+	}
+}
+
+// WithOverflowPolicy sets the policy applied when the buffer is full.
+// Default is DropOldest.
+func WithOverflowPolicy(p OverflowPolicy) SubscribeOption {
+	return func(c *subscribeConfig) error {
+		if p != DropOldest && p != DropNewest {
+			return errors.New("unknown overflow policy")
+		}
+		c.policy = p
+		return nil
+	}
+}
+
+// Subscription delivers unsolicited updates from the device.
+// The device is never blocked by a slow subscriber: once the buffer is full,
+// updates are discarded according to the OverflowPolicy and counted.
+type Subscription struct {
+	client  *Client
+	ch      chan Update
+	policy  OverflowPolicy
+	dropped atomic.Uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Subscribe registers a new consumer of unsolicited updates.
+// The subscription ends when ctx is done, when Close is called on it, or
+// when the client is closed; the Updates channel is then closed.
+func (c *Client) Subscribe(ctx context.Context, opts ...SubscribeOption) (*Subscription, error) {
+	cfg := &subscribeConfig{
+		bufferSize: DefaultSubscriptionBuffer,
+		policy:     DropOldest,
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, fmt.Errorf("invalid option: %w", err)
+		}
+	}
+
+	s := &Subscription{
+		client: c,
+		ch:     make(chan Update, cfg.bufferSize),
+		policy: cfg.policy,
+		done:   make(chan struct{}),
+	}
+
+	c.subMu.Lock()
+	select {
+	case <-c.closeCh:
+		c.subMu.Unlock()
+		return nil, fmt.Errorf("subscribe: %w", ErrClosed)
+	default:
+	}
+	c.subscribers[s] = struct{}{}
+	c.subMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.done:
+		}
+	}()
+
+	return s, nil
+}
+
+// Updates returns the channel on which updates are delivered.
+func (s *Subscription) Updates() <-chan Update {
+	return s.ch
+}
+
+// Dropped returns the number of updates discarded because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription and closes the Updates channel.
+func (s *Subscription) Close() {
+	s.client.subMu.Lock()
+	defer s.client.subMu.Unlock()
+	s.closeLocked()
+}
+
+// closeLocked removes the subscription from its client. c.subMu must be held.
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.client.subscribers, s)
+		close(s.done)
+		close(s.ch)
+	})
+}
+
+// deliver hands an update to the subscriber without blocking.
+// c.subMu must be held.
+func (s *Subscription) deliver(u Update) {
+	select {
+	case s.ch <- u:
+		return
+	default:
+	}
+
+	if s.policy == DropOldest {
+		select {
+		case <-s.ch:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.ch <- u:
+			return
+		default:
+		}
+	}
+	s.dropped.Add(1)
+}
+
+// publish decodes an unsolicited packet and fans it out to all subscribers.
+func (c *Client) publish(p *Packet) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if len(c.subscribers) == 0 {
+		return
+	}
+
+	u := Update{Packet: p}
+	if p.MsgType == MsgTypeControlStatus && len(p.Data) > 0 {
+		var err error
+		switch p.Data[0] {
+		case SubMsgTypeGroupStatus:
+			u.Groups, err = UnmarshalGroupStatus(p.Data)
+		case SubMsgTypeACStatus:
+			u.ACs, err = UnmarshalACStatus(p.Data)
+		}
+		if err != nil && c.logger != nil {
+			c.logger.Warn("failed to decode unsolicited packet", "msgID", p.MsgID, "error", err)
+		}
+	}
+
+	for s := range c.subscribers {
+		s.deliver(u)
+	}
+}
+
+// closeSubscribers ends every subscription. Called when the client closes.
+func (c *Client) closeSubscribers() {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for s := range c.subscribers {
+		s.closeLocked()
+	}
+}
@@ -0,0 +1,201 @@
+package at2plus
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receiveUpdate(t *testing.T, s *Subscription) Update {
+	t.Helper()
+	select {
+	case u, ok := <-s.Updates():
+		require.True(t, ok, "updates channel closed")
+		return u
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+		return Update{}
+	}
+}
+
+func TestSubscribe_UnsolicitedGroupStatus(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	s1, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+	s2, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	writeResponse(t, conn, 0x42, MsgTypeControlStatus, specGroupStatusData)
+
+	for _, s := range []*Subscription{s1, s2} {
+		u := receiveUpdate(t, s)
+		require.Len(t, u.Groups, 2)
+		assert.Nil(t, u.ACs)
+		assert.Equal(t, uint8(0x42), u.Packet.MsgID)
+	}
+}
+
+func TestSubscribe_UnsolicitedACStatus(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	s, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	data, _ := hex.DecodeString("230000000001000A101278C002DA00008000")
+	writeResponse(t, conn, 0x10, MsgTypeControlStatus, data)
+
+	u := receiveUpdate(t, s)
+	require.Len(t, u.ACs, 1)
+	assert.Equal(t, ACModeHeat, u.ACs[0].Mode)
+}
+
+func TestSubscribe_UnknownPacketDeliveredRaw(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	s, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	writeResponse(t, conn, 0x07, MsgTypeExtended, []byte{0xFF, 0x99, 0x01})
+
+	u := receiveUpdate(t, s)
+	assert.Nil(t, u.Groups)
+	assert.Nil(t, u.ACs)
+	assert.Equal(t, []byte{0xFF, 0x99, 0x01}, u.Packet.Data)
+}
+
+// lockedBuffer is a bytes.Buffer safe for the client's goroutines to log to.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSubscribe_MalformedStatusLogged(t *testing.T) {
+	var logs lockedBuffer
+	client, conn := newFakeDevice(t).dial(t, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	s, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	// One group with a repeat length of 0.
+	bad := []byte{SubMsgTypeGroupStatus, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00}
+	writeResponse(t, conn, 0x42, MsgTypeControlStatus, bad)
+
+	u := receiveUpdate(t, s)
+	assert.Nil(t, u.Groups)
+	assert.Equal(t, bad, u.Packet.Data)
+	assert.Contains(t, logs.String(), "failed to decode unsolicited packet")
+
+	writeResponse(t, conn, 0x43, MsgTypeControlStatus, specGroupStatusData)
+	u = receiveUpdate(t, s)
+	assert.Len(t, u.Groups, 2)
+}
+
+func TestSubscribe_ResponsesNotPublished(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	s, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	go func() {
+		req := readRequest(t, conn)
+		writeResponse(t, conn, req.MsgID, MsgTypeControlStatus, specGroupStatusData)
+	}()
+	_, err = client.GetGroupStatus(context.Background())
+	require.NoError(t, err)
+
+	select {
+	case u := <-s.Updates():
+		t.Fatalf("unexpected update: %+v", u)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubscribe_DropOldest(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	s, err := client.Subscribe(context.Background(), WithBufferSize(1))
+	require.NoError(t, err)
+
+	for id := uint8(1); id <= 3; id++ {
+		writeResponse(t, conn, 100+id, MsgTypeControlStatus, specGroupStatusData)
+	}
+	require.Eventually(t, func() bool { return s.Dropped() == 2 }, time.Second, 5*time.Millisecond)
+
+	u := receiveUpdate(t, s)
+	assert.Equal(t, uint8(103), u.Packet.MsgID)
+}
+
+func TestSubscribe_DropNewest(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t)
+
+	s, err := client.Subscribe(context.Background(), WithBufferSize(1), WithOverflowPolicy(DropNewest))
+	require.NoError(t, err)
+
+	for id := uint8(1); id <= 3; id++ {
+		writeResponse(t, conn, 100+id, MsgTypeControlStatus, specGroupStatusData)
+	}
+	require.Eventually(t, func() bool { return s.Dropped() == 2 }, time.Second, 5*time.Millisecond)
+
+	u := receiveUpdate(t, s)
+	assert.Equal(t, uint8(101), u.Packet.MsgID)
+}
+
+func TestSubscribe_ContextCancelClosesChannel(t *testing.T) {
+	client, _ := newFakeDevice(t).dial(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := client.Subscribe(ctx)
+	require.NoError(t, err)
+	cancel()
+
+	select {
+	case _, ok := <-s.Updates():
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after context cancel")
+	}
+}
+
+func TestSubscribe_ClientCloseClosesChannel(t *testing.T) {
+	client, _ := newFakeDevice(t).dial(t)
+
+	s, err := client.Subscribe(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+
+	_, ok := <-s.Updates()
+	assert.False(t, ok)
+
+	_, err = client.Subscribe(context.Background())
+	assert.Error(t, err)
+}
+
+func TestSubscribeOptions_Invalid(t *testing.T) {
+	client, _ := newFakeDevice(t).dial(t)
+
+	_, err := client.Subscribe(context.Background(), WithBufferSize(0))
+	assert.Error(t, err)
+
+	_, err = client.Subscribe(context.Background(), WithOverflowPolicy(OverflowPolicy(9)))
+	assert.Error(t, err)
+}
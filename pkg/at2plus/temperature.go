@@ -0,0 +1,127 @@
+package at2plus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Temperature is a temperature in tenths of a degree Celsius, the
+// resolution the AirTouch 2+ reports setpoints and readings in.
+// For example, 225 is 22.5°C and -13 is -1.3°C.
+type Temperature int
+
+// Setpoint limits accepted by AC control messages.
+const (
+	MinSetpoint Temperature = 100 // 10.0°C
+	MaxSetpoint Temperature = 350 // 35.0°C
+)
+
+// TemperatureUnit selects Celsius or Fahrenheit for parsing and display.
+type TemperatureUnit int
+
+const (
+	UnitCelsius TemperatureUnit = iota
+	UnitFahrenheit
+)
+
+// Celsius returns the Temperature for c degrees Celsius, rounded to the
+// nearest tenth (halves away from zero).
+func Celsius(c float64) Temperature {
+	return Temperature(math.Round(c * 10))
+}
+
+// Fahrenheit returns the Temperature for f degrees Fahrenheit, rounded to
+// the nearest tenth of a degree Celsius (halves away from zero).
+func Fahrenheit(f float64) Temperature {
+	return Celsius((f - 32) * 5 / 9)
+}
+
+// Celsius returns the temperature in degrees Celsius.
+func (t Temperature) Celsius() float64 {
+	return float64(t) / 10
+}
+
+// Fahrenheit returns the temperature in degrees Fahrenheit.
+func (t Temperature) Fahrenheit() float64 {
+	return float64(t)*9/50 + 32
+}
+
+// In returns the temperature in the given unit.
+func (t Temperature) In(unit TemperatureUnit) float64 {
+	if unit == UnitFahrenheit {
+		return t.Fahrenheit()
+	}
+	return t.Celsius()
+}
+
+// Format returns the temperature with one decimal and the unit symbol,
+// e.g. "22.5°C" or "72.5°F".
+func (t Temperature) Format(unit TemperatureUnit) string {
+	return fmt.Sprintf("%.1f%s", t.In(unit), unit.Symbol())
+}
+
+// String returns the temperature in Celsius, e.g. "22.5°C".
+func (t Temperature) String() string {
+	return t.Format(UnitCelsius)
+}
+
+// MarshalJSON encodes the temperature as a number of degrees Celsius.
+func (t Temperature) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(t.Celsius(), 'f', 1, 64)), nil
+}
+
+// UnmarshalJSON decodes a number of degrees Celsius.
+func (t *Temperature) UnmarshalJSON(b []byte) error {
+	c, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("invalid temperature %s: %w", b, err)
+	}
+	*t = Celsius(c)
+	return nil
+}
+
+// ParseTemperature parses a number such as "22.5" in the given unit.
+// A trailing "C" or "F" (optionally with "°") overrides the unit.
+func ParseTemperature(s string, unit TemperatureUnit) (Temperature, error) {
+	num := strings.TrimSpace(s)
+	switch {
+	case strings.HasSuffix(strings.ToUpper(num), "C"):
+		unit = UnitCelsius
+		num = num[:len(num)-1]
+	case strings.HasSuffix(strings.ToUpper(num), "F"):
+		unit = UnitFahrenheit
+		num = num[:len(num)-1]
+	}
+	num = strings.TrimSuffix(strings.TrimSpace(num), "°")
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid temperature %q", s)
+	}
+	if unit == UnitFahrenheit {
+		return Fahrenheit(v), nil
+	}
+	return Celsius(v), nil
+}
+
+// ParseTemperatureUnit parses "C"/"celsius" or "F"/"fahrenheit".
+func ParseTemperatureUnit(s string) (TemperatureUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "c", "celsius":
+		return UnitCelsius, nil
+	case "f", "fahrenheit":
+		return UnitFahrenheit, nil
+	default:
+		return 0, fmt.Errorf("unknown temperature unit %q (valid: C, F)", s)
+	}
+}
+
+// Symbol returns "°C" or "°F".
+func (u TemperatureUnit) Symbol() string {
+	if u == UnitFahrenheit {
+		return "°F"
+	}
+	return "°C"
+}
@@ -0,0 +1,81 @@
+package at2plus
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCelsius_Rounding(t *testing.T) {
+	assert.Equal(t, Temperature(225), Celsius(22.5))
+	assert.Equal(t, Temperature(239), Celsius(23.94))
+	assert.Equal(t, Temperature(240), Celsius(23.95))
+	assert.Equal(t, Temperature(-13), Celsius(-1.3))
+	assert.Equal(t, Temperature(-2), Celsius(-0.15)) // Halves round away from zero
+	assert.Equal(t, Temperature(-1), Celsius(-0.14))
+}
+
+func TestFahrenheit(t *testing.T) {
+	assert.Equal(t, Celsius(0), Fahrenheit(32))
+	assert.Equal(t, Celsius(22.5), Fahrenheit(72.5))
+	assert.Equal(t, Celsius(-40), Fahrenheit(-40))
+
+	assert.InDelta(t, 72.5, Celsius(22.5).Fahrenheit(), 0.001)
+	assert.InDelta(t, -40, Celsius(-40).Fahrenheit(), 0.001)
+}
+
+func TestTemperature_Format(t *testing.T) {
+	assert.Equal(t, "23.9°C", Temperature(239).String())
+	assert.Equal(t, "-1.3°C", Temperature(-13).String())
+	assert.Equal(t, "72.5°F", Celsius(22.5).Format(UnitFahrenheit))
+}
+
+func TestParseTemperature(t *testing.T) {
+	tests := []struct {
+		in   string
+		unit TemperatureUnit
+		want Temperature
+	}{
+		{"22.5", UnitCelsius, 225},
+		{"22", UnitCelsius, 220},
+		{"72.5", UnitFahrenheit, 225},
+		{"72.5F", UnitCelsius, 225},
+		{"22.5°C", UnitFahrenheit, 225},
+		{" -3.2 ", UnitCelsius, -32},
+	}
+	for _, tt := range tests {
+		got, err := ParseTemperature(tt.in, tt.unit)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, bad := range []string{"", "hot", "NaN", "22.5K"} {
+		_, err := ParseTemperature(bad, UnitCelsius)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseTemperatureUnit(t *testing.T) {
+	u, err := ParseTemperatureUnit("F")
+	require.NoError(t, err)
+	assert.Equal(t, UnitFahrenheit, u)
+
+	u, err = ParseTemperatureUnit("celsius")
+	require.NoError(t, err)
+	assert.Equal(t, UnitCelsius, u)
+
+	_, err = ParseTemperatureUnit("K")
+	assert.Error(t, err)
+}
+
+func TestTemperature_JSON(t *testing.T) {
+	data, err := json.Marshal(struct{ T Temperature }{Celsius(23.9)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"T":23.9}`, string(data))
+
+	var v struct{ T Temperature }
+	require.NoError(t, json.Unmarshal([]byte(`{"T":-1.3}`), &v))
+	assert.Equal(t, Temperature(-13), v.T)
+}
@@ -0,0 +1,256 @@
+package at2plus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ValidationMode decides how AC control commands are checked against the
+// capabilities the AC reports.
+type ValidationMode int
+
+const (
+	// ValidateOff sends commands as given. The device silently ignores
+	// settings the AC does not support.
+	ValidateOff ValidationMode = iota
+	// ValidateReject fails commands that request an unsupported mode or
+	// fan speed, or a setpoint outside the AC's range.
+	ValidateReject
+	// ValidateClamp moves out-of-range setpoints to the nearest limit.
+	// Unsupported modes and fan speeds are still rejected.
+	ValidateClamp
+)
+
+// FieldError describes one field of an AC control command that the AC
+// does not support.
+type FieldError struct {
+	ACNumber uint8
+	// Field is the ACControl field name, e.g. "Mode" or "Setpoint".
+	Field string
+	// Value is the requested value.
+	Value string
+	// Reason explains why the value was rejected.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return fmt.Sprintf("AC %d %s %s: %s", e.ACNumber, fieldDisplayName(e.Field), e.Value, e.Reason)
+}
+
+// fieldDisplayName turns a field name into words for messages: "FanSpeed"
+// becomes "fan speed" and "ACNumber" becomes "AC number".
+func fieldDisplayName(field string) string {
+	var words []string
+	start := 0
+	for i := 1; i < len(field); i++ {
+		upper := isUpper(field[i])
+		if upper && (!isUpper(field[i-1]) || i+1 < len(field) && !isUpper(field[i+1])) {
+			words = append(words, field[start:i])
+			start = i
+		}
+	}
+	words = append(words, field[start:])
+	for i, w := range words {
+		if len(w) == 1 || strings.ToUpper(w) != w {
+			words[i] = strings.ToLower(w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func isUpper(b byte) bool {
+	return 'A' <= b && b <= 'Z'
+}
+
+// ValidationError is returned when an AC control command fails validation.
+// Use errors.As to inspect the individual field errors.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid AC control: " + strings.Join(msgs, "; ")
+}
+
+// SupportsMode reports whether the AC supports mode m.
+// An ability that lists no modes at all is treated as supporting every mode.
+func (a ACAbility) SupportsMode(m ACMode) bool {
+	if !a.CoolMode && !a.FanMode && !a.DryMode && !a.HeatMode && !a.AutoMode {
+		return true
+	}
+	switch m {
+	case ACModeAuto:
+		return a.AutoMode
+	case ACModeHeat:
+		return a.HeatMode
+	case ACModeDry:
+		return a.DryMode
+	case ACModeFan:
+		return a.FanMode
+	case ACModeCool:
+		return a.CoolMode
+	default:
+		return false
+	}
+}
+
+// SupportsFanSpeed reports whether the AC supports fan speed f.
+// An ability that lists no fan speeds at all is treated as supporting every
+// fan speed.
+func (a ACAbility) SupportsFanSpeed(f FanSpeed) bool {
+	if !a.FanAuto && !a.FanQuiet && !a.FanLow && !a.FanMed && !a.FanHigh && !a.FanPowerful && !a.FanTurbo {
+		return true
+	}
+	switch f {
+	case FanSpeedAuto:
+		return a.FanAuto
+	case FanSpeedQuiet:
+		return a.FanQuiet
+	case FanSpeedLow:
+		return a.FanLow
+	case FanSpeedMedium:
+		return a.FanMed
+	case FanSpeedHigh:
+		return a.FanHigh
+	case FanSpeedPowerful:
+		return a.FanPowerful
+	case FanSpeedTurbo:
+		return a.FanTurbo
+	default:
+		return false
+	}
+}
+
+// SetpointRange returns the setpoint limits of the AC in mode m.
+// Cool and heat use their own limits; any other mode, or nil when the mode
+// is not known, uses the widest range covering both. A range the AC does
+// not report (all zero) falls back to MinSetpoint..MaxSetpoint.
+func (a ACAbility) SetpointRange(m *ACMode) (lo, hi Temperature) {
+	cool := [2]int{a.MinCoolSet, a.MaxCoolSet}
+	heat := [2]int{a.MinHeatSet, a.MaxHeatSet}
+	if cool == [2]int{} {
+		cool = heat
+	}
+	if heat == [2]int{} {
+		heat = cool
+	}
+
+	var r [2]int
+	switch {
+	case m != nil && *m == ACModeCool:
+		r = cool
+	case m != nil && *m == ACModeHeat:
+		r = heat
+	default:
+		r = [2]int{min(cool[0], heat[0]), max(cool[1], heat[1])}
+	}
+	if r == [2]int{} {
+		return MinSetpoint, MaxSetpoint
+	}
+	return Celsius(float64(r[0])), Celsius(float64(r[1]))
+}
+
+// ValidateACControl checks an AC control command against the AC's ability.
+// In ValidateClamp mode the returned command has its setpoint clamped to the
+// AC's range; otherwise it is returned unchanged. Any unsupported field is
+// reported in a *ValidationError.
+func ValidateACControl(ab ACAbility, ctrl ACControl, mode ValidationMode) (ACControl, error) {
+	if mode == ValidateOff {
+		return ctrl, nil
+	}
+
+	var errs []FieldError
+	fail := func(field, value, reason string) {
+		errs = append(errs, FieldError{ACNumber: ctrl.ACNumber, Field: field, Value: value, Reason: reason})
+	}
+
+	if ctrl.Power != nil && !ctrl.Power.Valid() {
+		fail("Power", ctrl.Power.String(), "unknown power command")
+	}
+	if ctrl.Mode != nil && !ab.SupportsMode(*ctrl.Mode) {
+		fail("Mode", ctrl.Mode.String(), "not supported by this AC")
+	}
+	if ctrl.FanSpeed != nil && !ab.SupportsFanSpeed(*ctrl.FanSpeed) {
+		fail("FanSpeed", ctrl.FanSpeed.String(), "not supported by this AC")
+	}
+	if ctrl.Setpoint != nil {
+		lo, hi := ab.SetpointRange(ctrl.Mode)
+		sp := *ctrl.Setpoint
+		if sp < lo || sp > hi {
+			if mode == ValidateClamp {
+				sp = max(lo, min(sp, hi))
+				ctrl.Setpoint = &sp
+			} else {
+				fail("Setpoint", sp.String(), fmt.Sprintf("out of range %s-%s", lo, hi))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return ctrl, &ValidationError{Errors: errs}
+	}
+	return ctrl, nil
+}
+
+// ACAbilityCached returns the ability of an AC, fetching it from the device
+// on first use. Abilities do not change while the system runs, so the
+// result is kept for the lifetime of the client.
+func (c *Client) ACAbilityCached(ctx context.Context, acNum uint8) (ACAbility, error) {
+	c.abilityMu.Lock()
+	ab, ok := c.abilities[acNum]
+	c.abilityMu.Unlock()
+	if ok {
+		return ab, nil
+	}
+
+	abilities, err := c.GetACAbility(ctx, acNum)
+	if err != nil {
+		return ACAbility{}, err
+	}
+
+	c.abilityMu.Lock()
+	defer c.abilityMu.Unlock()
+	for _, a := range abilities {
+		c.abilities[a.ACNumber] = a
+	}
+	ab, ok = c.abilities[acNum]
+	if !ok {
+		return ACAbility{}, fmt.Errorf("get AC ability (AC %d): not reported by device", acNum)
+	}
+	return ab, nil
+}
+
+// ValidateACControl checks AC control commands against the cached abilities
+// of their ACs, as SetACControl does when the client was created with
+// WithValidation. Field errors of all commands are collected into a single
+// *ValidationError.
+func (c *Client) ValidateACControl(ctx context.Context, acs []ACControl, mode ValidationMode) ([]ACControl, error) {
+	if mode == ValidateOff {
+		return acs, nil
+	}
+
+	out := make([]ACControl, len(acs))
+	var errs []FieldError
+	for i, ctrl := range acs {
+		ab, err := c.ACAbilityCached(ctx, ctrl.ACNumber)
+		if err != nil {
+			return nil, fmt.Errorf("validate AC control: %w", err)
+		}
+		out[i], err = ValidateACControl(ab, ctrl, mode)
+		if ve, ok := err.(*ValidationError); ok {
+			errs = append(errs, ve.Errors...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package at2plus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// coolOnlyAbility is a cooling-only AC with three fan speeds.
+var coolOnlyAbility = ACAbility{
+	ACNumber:   0,
+	CoolMode:   true,
+	FanMode:    true,
+	DryMode:    true,
+	FanLow:     true,
+	FanMed:     true,
+	FanHigh:    true,
+	MinCoolSet: 18,
+	MaxCoolSet: 30,
+}
+
+func TestValidateACControl_Reject(t *testing.T) {
+	ctrl := ACControl{
+		ACNumber: 0,
+		Mode:     Ptr(ACModeHeat),
+		FanSpeed: Ptr(FanSpeedTurbo),
+		Setpoint: Ptr(Celsius(16)),
+	}
+
+	_, err := ValidateACControl(coolOnlyAbility, ctrl, ValidateReject)
+	var ve *ValidationError
+	require.True(t, errors.As(err, &ve))
+	require.Len(t, ve.Errors, 3)
+	assert.Equal(t, "Mode", ve.Errors[0].Field)
+	assert.Equal(t, "FanSpeed", ve.Errors[1].Field)
+	assert.Equal(t, "Setpoint", ve.Errors[2].Field)
+	assert.Equal(t, "AC 0 fan speed turbo: not supported by this AC", ve.Errors[1].Error())
+	assert.Equal(t, "AC 0 setpoint 16.0°C: out of range 18.0°C-30.0°C", ve.Errors[2].Error())
+}
+
+func TestFieldDisplayName(t *testing.T) {
+	for field, want := range map[string]string{
+		"Mode":        "mode",
+		"FanSpeed":    "fan speed",
+		"ACNumber":    "AC number",
+		"GroupNumber": "group number",
+	} {
+		assert.Equal(t, want, fieldDisplayName(field), field)
+	}
+}
+
+func TestValidateACControl_Clamp(t *testing.T) {
+	ctrl := ACControl{ACNumber: 0, Mode: Ptr(ACModeCool), Setpoint: Ptr(Celsius(32.5))}
+
+	got, err := ValidateACControl(coolOnlyAbility, ctrl, ValidateClamp)
+	require.NoError(t, err)
+	assert.Equal(t, Celsius(30), *got.Setpoint)
+	assert.Equal(t, Celsius(32.5), *ctrl.Setpoint, "input must not be modified")
+
+	// Clamping never makes an unsupported mode acceptable
+	_, err = ValidateACControl(coolOnlyAbility, ACControl{Mode: Ptr(ACModeHeat)}, ValidateClamp)
+	assert.Error(t, err)
+}
+
+func TestValidateACControl_Supported(t *testing.T) {
+	ctrl := ACControl{
+		ACNumber: 0,
+		Power:    Ptr(ACPowerOn),
+		Mode:     Ptr(ACModeCool),
+		FanSpeed: Ptr(FanSpeedMedium),
+		Setpoint: Ptr(Celsius(22.5)),
+	}
+
+	got, err := ValidateACControl(coolOnlyAbility, ctrl, ValidateReject)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl, got)
+}
+
+func TestACAbility_SetpointRange(t *testing.T) {
+	ab := ACAbility{MinCoolSet: 18, MaxCoolSet: 30, MinHeatSet: 16, MaxHeatSet: 28}
+
+	lo, hi := ab.SetpointRange(Ptr(ACModeCool))
+	assert.Equal(t, []Temperature{Celsius(18), Celsius(30)}, []Temperature{lo, hi})
+	lo, hi = ab.SetpointRange(Ptr(ACModeHeat))
+	assert.Equal(t, []Temperature{Celsius(16), Celsius(28)}, []Temperature{lo, hi})
+	lo, hi = ab.SetpointRange(nil)
+	assert.Equal(t, []Temperature{Celsius(16), Celsius(30)}, []Temperature{lo, hi})
+
+	// No reported limits
+	lo, hi = ACAbility{}.SetpointRange(nil)
+	assert.Equal(t, []Temperature{MinSetpoint, MaxSetpoint}, []Temperature{lo, hi})
+}
+
+func TestClient_SetACControl_Validation(t *testing.T) {
+	client, conn := newFakeDevice(t).dial(t, WithValidation(ValidateReject))
+	abilityData, err := MarshalACAbility([]ACAbility{coolOnlyAbility})
+	require.NoError(t, err)
+
+	// Only the ability is requested, and only once
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := readRequest(t, conn)
+		assert.Equal(t, []byte{0xFF, ExtMsgTypeACAbility, 0x00}, req.Data)
+		writeResponse(t, conn, req.MsgID, MsgTypeExtended, abilityData)
+	}()
+
+	ctx := context.Background()
+	err = client.SetACControl(ctx, []ACControl{{ACNumber: 0, Mode: Ptr(ACModeHeat)}})
+	var ve *ValidationError
+	require.True(t, errors.As(err, &ve))
+	assert.Equal(t, "Mode", ve.Errors[0].Field)
+	<-done
+
+	err = client.SetACControl(ctx, []ACControl{{ACNumber: 0, Setpoint: Ptr(Celsius(35))}})
+	assert.True(t, errors.As(err, &ve))
+}